@@ -0,0 +1,108 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+)
+
+// StackDefaults are settings applied to Stacks which don't set them themselves.
+type StackDefaults struct {
+	// Tags applied to all CloudFormation stacks in addition to the tags of their Stack.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// Capabilities granted to all CloudFormation stacks, e.g. CAPABILITY_IAM.
+	// +optional
+	Capabilities []string `json:"capabilities,omitempty"`
+	// DryRun stops the operator from creating, updating or deleting CloudFormation stacks.
+	// +optional
+	DryRun *bool `json:"dryRun,omitempty"`
+}
+
+// StackPolicy restricts the Stacks the operator submits to CloudFormation.
+type StackPolicy struct {
+	// RequiredTags are the keys of tags every CloudFormation stack must have, either from the defaults
+	// or from the Stack.
+	// +optional
+	RequiredTags []string `json:"requiredTags,omitempty"`
+}
+
+// NamespaceConfig overrides the defaults and policy for the Stacks of one namespace.
+type NamespaceConfig struct {
+	// Tags are added to the default tags.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// Capabilities replace the default capabilities.
+	// +optional
+	Capabilities []string `json:"capabilities,omitempty"`
+	// DryRun replaces the default.
+	// +optional
+	DryRun *bool `json:"dryRun,omitempty"`
+	// RequiredTags are required in addition to those of the policy.
+	// +optional
+	RequiredTags []string `json:"requiredTags,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// OperatorConfig is the configuration file of the operator, given with --config. Besides the settings of
+// the controller manager, it carries the settings of the Stacks. Changes to defaults, policy and namespaces
+// take effect without restarting the operator.
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ControllerManagerConfigurationSpec returns the configuration of the controller manager.
+	cfg.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// Region is the AWS region to use, it is only read on startup.
+	// +optional
+	Region string `json:"region,omitempty"`
+	// AssumeRole is the ARN of an AWS role to assume, it is only read on startup.
+	// +optional
+	AssumeRole string `json:"assumeRole,omitempty"`
+	// ClusterName is available as .Operator.ClusterName when rendering Stacks, it is only read on startup.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// Defaults are applied to the Stacks of all namespaces.
+	// +optional
+	Defaults StackDefaults `json:"defaults,omitempty"`
+	// Policy applies to the Stacks of all namespaces.
+	// +optional
+	Policy StackPolicy `json:"policy,omitempty"`
+	// Namespaces override the defaults and policy by namespace name.
+	// +optional
+	Namespaces map[string]NamespaceConfig `json:"namespaces,omitempty"`
+}
+
+// Complete implements config.ControllerManagerConfiguration.
+func (c *OperatorConfig) Complete() (cfg.ControllerManagerConfigurationSpec, error) {
+	return c.ControllerManagerConfigurationSpec, nil
+}
+
+func init() {
+	SchemeBuilder.Register(&OperatorConfig{})
+}
@@ -0,0 +1,137 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseTemplate parses a CloudFormation template given in JSON or YAML into its generic
+// representation. Short-form intrinsic functions like `!Ref` or `!Sub` are expanded to
+// their long form, e.g. `!Ref Foo` becomes `{"Ref": "Foo"}`.
+func ParseTemplate(body string) (map[string]interface{}, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(body), &document); err != nil {
+		return nil, err
+	}
+	if document.Kind == 0 {
+		return nil, fmt.Errorf("template is empty")
+	}
+
+	value, err := templateValue(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	template, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("template must be a mapping")
+	}
+	return template, nil
+}
+
+// templateValue converts a YAML node to plain Go values, expanding CloudFormation short-form tags.
+func templateValue(node *yaml.Node) (interface{}, error) {
+	if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+		return intrinsicValue(node)
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return templateValue(node.Content[0])
+	case yaml.AliasNode:
+		return templateValue(node.Alias)
+	case yaml.MappingNode:
+		mapping := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+			}
+			value, err := templateValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			mapping[key.Value] = value
+		}
+		return mapping, nil
+	case yaml.SequenceNode:
+		sequence := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := templateValue(item)
+			if err != nil {
+				return nil, err
+			}
+			sequence = append(sequence, value)
+		}
+		return sequence, nil
+	default:
+		// CloudFormation reads dates like AWSTemplateFormatVersion: 2010-09-09 as strings.
+		if node.ShortTag() == "!!timestamp" {
+			return node.Value, nil
+		}
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", node.Line, err)
+		}
+		return value, nil
+	}
+}
+
+// intrinsicValue expands a node tagged with a short-form intrinsic function.
+func intrinsicValue(node *yaml.Node) (interface{}, error) {
+	name := strings.TrimPrefix(node.Tag, "!")
+
+	untagged := *node
+	untagged.Tag = ""
+	if node.Kind == yaml.ScalarNode {
+		untagged.Tag = "!!str"
+	}
+	value, err := templateValue(&untagged)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "Ref", "Condition":
+		return map[string]interface{}{name: value}, nil
+	case "GetAtt":
+		// !GetAtt Resource.Attribute is the short form of [Resource, Attribute]
+		if attribute, ok := value.(string); ok {
+			parts := strings.SplitN(attribute, ".", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("line %d: !GetAtt requires the form Resource.Attribute", node.Line)
+			}
+			value = []interface{}{parts[0], parts[1]}
+		}
+	}
+	return map[string]interface{}{"Fn::" + name: value}, nil
+}
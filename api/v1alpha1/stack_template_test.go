@@ -0,0 +1,116 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		body    string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "json",
+			body: `{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket"}}}`,
+			want: map[string]interface{}{
+				"Resources": map[string]interface{}{"Bucket": map[string]interface{}{"Type": "AWS::S3::Bucket"}},
+			},
+		},
+		{
+			name: "unquoted date",
+			body: "AWSTemplateFormatVersion: 2010-09-09\n",
+			want: map[string]interface{}{"AWSTemplateFormatVersion": "2010-09-09"},
+		},
+		{
+			name: "ref",
+			body: "Value: !Ref Bucket\n",
+			want: map[string]interface{}{"Value": map[string]interface{}{"Ref": "Bucket"}},
+		},
+		{
+			name: "condition",
+			body: "Value: !Condition IsProduction\n",
+			want: map[string]interface{}{"Value": map[string]interface{}{"Condition": "IsProduction"}},
+		},
+		{
+			name: "sub",
+			body: "Value: !Sub '${AWS::StackName}-bucket'\n",
+			want: map[string]interface{}{"Value": map[string]interface{}{"Fn::Sub": "${AWS::StackName}-bucket"}},
+		},
+		{
+			name: "getatt short form",
+			body: "Value: !GetAtt Bucket.Arn\n",
+			want: map[string]interface{}{"Value": map[string]interface{}{"Fn::GetAtt": []interface{}{"Bucket", "Arn"}}},
+		},
+		{
+			name: "getatt sequence",
+			body: "Value: !GetAtt [Bucket, Arn]\n",
+			want: map[string]interface{}{"Value": map[string]interface{}{"Fn::GetAtt": []interface{}{"Bucket", "Arn"}}},
+		},
+		{
+			name: "nested",
+			body: "Value: !Join ['-', [!Ref AWS::StackName, !Select [0, !Split [',', 'a,b']]]]\n",
+			want: map[string]interface{}{"Value": map[string]interface{}{"Fn::Join": []interface{}{"-", []interface{}{
+				map[string]interface{}{"Ref": "AWS::StackName"},
+				map[string]interface{}{"Fn::Select": []interface{}{0, map[string]interface{}{"Fn::Split": []interface{}{",", "a,b"}}}},
+			}}}},
+		},
+		{
+			name:    "invalid getatt",
+			body:    "Value: !GetAtt Bucket\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			body:    "",
+			wantErr: true,
+		},
+		{
+			name:    "not a mapping",
+			body:    "- Bucket\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			body:    "Resources: [\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTemplate(tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTemplate() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
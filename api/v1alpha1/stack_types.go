@@ -25,7 +25,11 @@ SOFTWARE.
 package v1alpha1
 
 import (
+	"bytes"
+	"encoding/json"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -34,15 +38,63 @@ import (
 
 // Defines the desired state of Stack
 type StackSpec struct {
+	// Name of the CloudFormation stack, defaults to the name of the Stack resource. Cannot be updated.
+	// +kubebuilder:validation:Optional
+	StackName string `json:"stackName,omitempty"`
 	// +kubebuilder:validation:Optional
 	Parameters map[string]string `json:"parameters,omitempty"`
 	// +kubebuilder:validation:Optional
-	Tags     map[string]string `json:"tags,omitempty"`
-	Template string            `json:"template"`
+	Tags map[string]string `json:"tags,omitempty"`
+	// CloudFormation template as a JSON or YAML string. Either template or templateObject must be given.
+	// +kubebuilder:validation:Optional
+	Template string `json:"template,omitempty"`
+	// CloudFormation template as an embedded object, which is sent to CloudFormation as JSON.
+	// Either template or templateObject must be given.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	TemplateObject *runtime.RawExtension `json:"templateObject,omitempty"`
+	// Render the template and parameter values as Go templates before sending them to CloudFormation.
+	// +kubebuilder:validation:Optional
+	RenderTemplate bool `json:"renderTemplate,omitempty"`
+	// Class of the operator instance managing this Stack, instances only manage Stacks of their own class.
+	// Takes precedence over the cloudformation.linki.space/operator-class annotation.
+	// +kubebuilder:validation:Optional
+	OperatorClass string `json:"operatorClass,omitempty"`
+	// +kubebuilder:validation:Optional
+	Recovery *StackRecovery `json:"recovery,omitempty"`
+	// +kubebuilder:validation:Optional
+	Timeouts *StackTimeouts `json:"timeouts,omitempty"`
+}
+
+// Defines how the operator recovers a Stack from failed states
+type StackRecovery struct {
+	// Continue rolling back stacks in UPDATE_ROLLBACK_FAILED and retry the update afterwards.
+	// +kubebuilder:validation:Optional
+	ContinueUpdateRollback bool `json:"continueUpdateRollback,omitempty"`
+	// Logical IDs of the resources CloudFormation should skip when continuing the rollback.
+	// +kubebuilder:validation:Optional
+	ResourcesToSkip []string `json:"resourcesToSkip,omitempty"`
+	// Maximum number of times a stack whose creation failed is deleted and created again, defaults to 3.
+	// Set to 0 to leave failed stacks alone.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	RecreateAttempts *int32 `json:"recreateAttempts,omitempty"`
+}
+
+// Defines how long operations on a Stack may take
+type StackTimeouts struct {
+	// Time the creation of the stack may take before CloudFormation rolls it back, rounded up to whole minutes.
+	// +kubebuilder:validation:Optional
+	Create *metav1.Duration `json:"create,omitempty"`
+	// Time an update of the stack may take before the operator cancels it, which rolls it back.
+	// +kubebuilder:validation:Optional
+	Update *metav1.Duration `json:"update,omitempty"`
 }
 
 // Defines the observed state of Stack
 type StackStatus struct {
+	// +kubebuilder:validation:Optional
 	StackID string `json:"stackID"`
 	// +kubebuilder:validation:Optional
 	StackStatus string `json:"stackStatus"`
@@ -58,8 +110,133 @@ type StackStatus struct {
 	// +kubebuilder:validation:Optional
 	// +nullable
 	Resources []StackResource `json:"resources,omitEmpty"`
+	// Reason the original creation of the stack failed, kept while the stack is recreated.
+	// +kubebuilder:validation:Optional
+	FailureReason string `json:"failureReason,omitempty"`
+	// Number of times the stack was recreated after its creation failed.
+	// +kubebuilder:validation:Optional
+	RecreateAttempts int32 `json:"recreateAttempts,omitempty"`
+	// Value of the cloudformation.linki.space/reconcile-request annotation the operator acted on last.
+	// +kubebuilder:validation:Optional
+	LastHandledReconcileRequest string `json:"lastHandledReconcileRequest,omitempty"`
+	// Value of the cloudformation.linki.space/refresh-request annotation the operator acted on last.
+	// +kubebuilder:validation:Optional
+	LastHandledRefreshRequest string `json:"lastHandledRefreshRequest,omitempty"`
+	// Value of the cloudformation.linki.space/cancel-request annotation the operator acted on last.
+	// +kubebuilder:validation:Optional
+	LastHandledCancelRequest string `json:"lastHandledCancelRequest,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// ConditionTemplateRendered reports whether the template and parameters of a Stack with
+	// spec.renderTemplate enabled could be rendered.
+	ConditionTemplateRendered = "TemplateRendered"
+	// ConditionRecovering reports whether the operator is recovering a stack from UPDATE_ROLLBACK_FAILED.
+	ConditionRecovering = "Recovering"
+	// ConditionRecreating reports whether the operator is recreating a stack whose creation failed.
+	ConditionRecreating = "Recreating"
+	// ConditionSynced reports whether the desired state of a Stack was submitted to CloudFormation.
+	ConditionSynced = "Synced"
+	// ConditionPaused reports whether reconciling a Stack is paused by the PausedAnnotation.
+	ConditionPaused = "Paused"
+	// ConditionCancelling reports whether an update of a stack is being cancelled and rolled back.
+	ConditionCancelling = "Cancelling"
+
+	// ReasonRendered is used when the template and parameters were rendered successfully.
+	ReasonRendered = "Rendered"
+	// ReasonRenderFailed is used when rendering the template or parameters failed.
+	ReasonRenderFailed = "RenderFailed"
+	// ReasonContinueUpdateRollback is used when the rollback of a failed update is being continued.
+	ReasonContinueUpdateRollback = "ContinueUpdateRollback"
+	// ReasonRecovered is used when a stack was recovered from UPDATE_ROLLBACK_FAILED.
+	ReasonRecovered = "Recovered"
+	// ReasonRecoveryDisabled is used when a stack is in UPDATE_ROLLBACK_FAILED but recovery isn't enabled.
+	ReasonRecoveryDisabled = "RecoveryDisabled"
+	// ReasonRecoveryFailed is used when continuing the rollback of a failed update failed.
+	ReasonRecoveryFailed = "RecoveryFailed"
+	// ReasonWaitingToRecreate is used while backing off before recreating a stack whose creation failed.
+	ReasonWaitingToRecreate = "WaitingToRecreate"
+	// ReasonDeletingFailedStack is used while a stack whose creation failed is being deleted.
+	ReasonDeletingFailedStack = "DeletingFailedStack"
+	// ReasonRecreating is used while a stack whose creation failed is being created again.
+	ReasonRecreating = "Recreating"
+	// ReasonRecreated is used when a stack was created successfully after being recreated.
+	ReasonRecreated = "Recreated"
+	// ReasonRecreateAttemptsExhausted is used when a stack failed to be created too many times.
+	ReasonRecreateAttemptsExhausted = "RecreateAttemptsExhausted"
+	// ReasonSubmitted is used when a change to the stack was submitted to CloudFormation.
+	ReasonSubmitted = "Submitted"
+	// ReasonUpToDate is used when the stack already matches the Stack's spec.
+	ReasonUpToDate = "UpToDate"
+	// ReasonValidationError is used when CloudFormation rejected the template or parameters.
+	ReasonValidationError = "ValidationError"
+	// ReasonInsufficientCapabilities is used when the template needs capabilities the operator doesn't grant.
+	ReasonInsufficientCapabilities = "InsufficientCapabilities"
+	// ReasonPolicyViolation is used when a Stack violates the policy of the operator's config file.
+	ReasonPolicyViolation = "PolicyViolation"
+	// ReasonPaused is used while reconciling a Stack is paused.
+	ReasonPaused = "Paused"
+	// ReasonResumed is used when reconciling a Stack was resumed.
+	ReasonResumed = "Resumed"
+	// ReasonUpdateTimedOut is used when an update is cancelled because it took longer than spec.timeouts.update.
+	ReasonUpdateTimedOut = "UpdateTimedOut"
+	// ReasonCancelRequested is used when an update is cancelled as requested by the CancelRequestAnnotation.
+	ReasonCancelRequested = "CancelRequested"
+	// ReasonCancelled is used when a cancelled update was rolled back.
+	ReasonCancelled = "Cancelled"
+	// ReasonCancelUpdateFailed is used when an update couldn't be cancelled.
+	ReasonCancelUpdateFailed = "CancelUpdateFailed"
+)
+
+// Reasons of the Events the operator emits on a Stack. Besides these, the reasons of conditions are used for Events
+// about the same change. When a stack reaches a terminal state, the Event's reason is its status in PascalCase, e.g.
+// CreateComplete or UpdateRollbackComplete, and its type is Warning if the stack failed or was rolled back.
+const (
+	// ReasonCreating is used when the creation of a stack was submitted.
+	ReasonCreating = "Creating"
+	// ReasonUpdating is used when an update of a stack was submitted.
+	ReasonUpdating = "Updating"
+	// ReasonDeleting is used when the deletion of a stack was submitted.
+	ReasonDeleting = "Deleting"
+	// ReasonNotOwned is used when the operator refuses to change a stack it doesn't own.
+	ReasonNotOwned = "NotOwned"
+	// ReasonCreateStackFailed is used when CloudFormation refused to create a stack.
+	ReasonCreateStackFailed = "CreateStackFailed"
+	// ReasonUpdateStackFailed is used when CloudFormation refused to update a stack.
+	ReasonUpdateStackFailed = "UpdateStackFailed"
+	// ReasonDeleteStackFailed is used when CloudFormation refused to delete a stack.
+	ReasonDeleteStackFailed = "DeleteStackFailed"
+	// ReasonReconcileRequested is used when a reconcile was requested with the ReconcileRequestAnnotation.
+	ReasonReconcileRequested = "ReconcileRequested"
+	// ReasonRefreshRequested is used when a status refresh was requested with the RefreshRequestAnnotation.
+	ReasonRefreshRequested = "RefreshRequested"
+)
+
+// PollIntervalAnnotation sets a fixed interval, e.g. "30s", at which the operator polls the CloudFormation stack of
+// a Stack while an operation is in progress, instead of backing off adaptively.
+const PollIntervalAnnotation = "cloudformation.linki.space/poll-interval"
+
+// PausedAnnotation set to "true" stops the operator from creating or updating the stack of a Stack. The status
+// of the stack is still followed and it's deleted along with the Stack.
+const PausedAnnotation = "cloudformation.linki.space/paused"
+
+// ReconcileRequestAnnotation forces a reconcile, including an update of the stack, whenever its value changes,
+// e.g. to the current time. A stack whose creation failed is recreated without waiting for the backoff.
+const ReconcileRequestAnnotation = "cloudformation.linki.space/reconcile-request"
+
+// RefreshRequestAnnotation refreshes the status of a Stack from CloudFormation whenever its value changes.
+const RefreshRequestAnnotation = "cloudformation.linki.space/refresh-request"
+
+// CancelRequestAnnotation cancels the update of the stack in progress whenever its value changes.
+const CancelRequestAnnotation = "cloudformation.linki.space/cancel-request"
+
+// OperatorClassAnnotation sets the class of the operator instance managing a Stack, like spec.operatorClass.
+const OperatorClassAnnotation = "cloudformation.linki.space/operator-class"
+
 // Defines a resource provided/managed by a Stack and its current state
 type StackResource struct {
 	LogicalId  string `json:"logicalID"`
@@ -91,6 +268,49 @@ type StackList struct {
 	Items           []Stack `json:"items"`
 }
 
+// GetStackName returns the name of the CloudFormation stack managed by this Stack.
+func (s *Stack) GetStackName() string {
+	if s.Spec.StackName != "" {
+		return s.Spec.StackName
+	}
+	return s.Name
+}
+
+// IsPaused returns whether reconciling this Stack is paused by the PausedAnnotation.
+func (s *Stack) IsPaused() bool {
+	return s.Annotations[PausedAnnotation] == "true"
+}
+
+// GetOperatorClass returns the class of the operator instance managing this Stack, empty if it has none.
+func (s *Stack) GetOperatorClass() string {
+	if s.Spec.OperatorClass != "" {
+		return s.Spec.OperatorClass
+	}
+	return s.Annotations[OperatorClassAnnotation]
+}
+
+// GetRecreateAttempts returns how often a stack whose creation failed may be recreated.
+func (s *Stack) GetRecreateAttempts() int32 {
+	if s.Spec.Recovery == nil || s.Spec.Recovery.RecreateAttempts == nil {
+		return 3
+	}
+	return *s.Spec.Recovery.RecreateAttempts
+}
+
+// GetTemplateBody returns the CloudFormation template of this Stack, serialising spec.templateObject
+// to JSON when it is used instead of spec.template.
+func (s *Stack) GetTemplateBody() (string, error) {
+	if s.Spec.TemplateObject == nil || len(s.Spec.TemplateObject.Raw) == 0 {
+		return s.Spec.Template, nil
+	}
+
+	var body bytes.Buffer
+	if err := json.Compact(&body, s.Spec.TemplateObject.Raw); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
 func init() {
 	SchemeBuilder.Register(&Stack{}, &StackList{})
 }
@@ -0,0 +1,252 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	gotemplate "text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var stacklog = logf.Log.WithName("stack-resource")
+
+// templateSections are the top-level sections allowed in a CloudFormation template.
+var templateSections = map[string]bool{
+	"AWSTemplateFormatVersion": true,
+	"Description":              true,
+	"Metadata":                 true,
+	"Parameters":               true,
+	"Rules":                    true,
+	"Mappings":                 true,
+	"Conditions":               true,
+	"Transform":                true,
+	"Resources":                true,
+	"Outputs":                  true,
+}
+
+func (r *Stack) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-cloudformation-linki-space-v1alpha1-stack,mutating=false,failurePolicy=fail,sideEffects=None,groups=cloudformation.linki.space,resources=stacks,verbs=create;update,versions=v1alpha1,name=vstack.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &Stack{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Stack) ValidateCreate() error {
+	stacklog.Info("validate create", "name", r.Name)
+
+	return r.toInvalidError(append(r.validateSpec(), r.validateAnnotations()...))
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Stack) ValidateUpdate(old runtime.Object) error {
+	stacklog.Info("validate update", "name", r.Name)
+
+	oldStack, ok := old.(*Stack)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a Stack but got a %T", old))
+	}
+
+	// Don't block removing finalizers from a Stack that is being deleted.
+	if r.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	// Stacks created before the webhook or an update of it may not pass validation anymore, which mustn't keep
+	// the operator and users from changing their metadata, e.g. to add a finalizer.
+	var allErrs field.ErrorList
+	if !equality.Semantic.DeepEqual(r.Spec, oldStack.Spec) {
+		allErrs = append(allErrs, r.validateSpec()...)
+	}
+	if !equality.Semantic.DeepEqual(r.Annotations, oldStack.Annotations) {
+		allErrs = append(allErrs, r.validateAnnotations()...)
+	}
+	if r.GetStackName() != oldStack.GetStackName() {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec").Child("stackName"),
+			fmt.Sprintf("the CloudFormation stack name cannot be changed from %q", oldStack.GetStackName())))
+	}
+	if r.GetOperatorClass() != oldStack.GetOperatorClass() {
+		classPath := field.NewPath("spec").Child("operatorClass")
+		if r.Spec.OperatorClass == "" && oldStack.Spec.OperatorClass == "" {
+			classPath = field.NewPath("metadata").Child("annotations").Key(OperatorClassAnnotation)
+		}
+		allErrs = append(allErrs, field.Forbidden(classPath,
+			fmt.Sprintf("the operator class cannot be changed from %q", oldStack.GetOperatorClass())))
+	}
+
+	return r.toInvalidError(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Stack) ValidateDelete() error {
+	return nil
+}
+
+func (r *Stack) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Stack"}, r.Name, allErrs)
+}
+
+// validateAnnotations checks the values of the annotations the operator understands.
+func (r *Stack) validateAnnotations() field.ErrorList {
+	var allErrs field.ErrorList
+	annotationsPath := field.NewPath("metadata").Child("annotations")
+
+	if value, ok := r.Annotations[PollIntervalAnnotation]; ok {
+		if interval, err := time.ParseDuration(value); err != nil || interval <= 0 {
+			allErrs = append(allErrs, field.Invalid(annotationsPath.Key(PollIntervalAnnotation), value,
+				"must be a positive duration, e.g. 30s"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateSpec parses the template and checks the supplied parameters against it.
+func (r *Stack) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	templatePath := field.NewPath("spec").Child("template")
+
+	hasTemplateObject := r.Spec.TemplateObject != nil && len(r.Spec.TemplateObject.Raw) > 0
+	if hasTemplateObject {
+		if r.Spec.Template != "" {
+			return append(allErrs, field.Forbidden(field.NewPath("spec").Child("templateObject"),
+				"template and templateObject are mutually exclusive"))
+		}
+		templatePath = field.NewPath("spec").Child("templateObject")
+	}
+
+	body, err := r.GetTemplateBody()
+	if err != nil {
+		return append(allErrs, field.Invalid(templatePath, "", fmt.Sprintf("unable to serialise template: %v", err)))
+	}
+
+	// Rendered templates can only be checked for valid Go template syntax as the operator-level
+	// variables are only known to the operator.
+	if r.Spec.RenderTemplate {
+		if _, err := gotemplate.New("template").Parse(body); err != nil {
+			allErrs = append(allErrs, field.Invalid(templatePath, "", fmt.Sprintf("unable to parse Go template: %v", err)))
+		}
+		for _, key := range sortedKeys(r.Spec.Parameters) {
+			if _, err := gotemplate.New(key).Parse(r.Spec.Parameters[key]); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("parameters").Key(key),
+					r.Spec.Parameters[key], fmt.Sprintf("unable to parse Go template: %v", err)))
+			}
+		}
+		return allErrs
+	}
+
+	template, err := ParseTemplate(body)
+	if err != nil {
+		return append(allErrs, field.Invalid(templatePath, "", fmt.Sprintf("unable to parse template: %v", err)))
+	}
+
+	for _, section := range sortedKeys(template) {
+		if !templateSections[section] {
+			allErrs = append(allErrs, field.Invalid(templatePath, section, "unknown template section"))
+		}
+	}
+
+	if version, ok := template["AWSTemplateFormatVersion"]; ok && fmt.Sprint(version) != "2010-09-09" {
+		allErrs = append(allErrs, field.Invalid(templatePath, version, "AWSTemplateFormatVersion must be 2010-09-09"))
+	}
+
+	resources, ok := template["Resources"].(map[string]interface{})
+	if !ok || len(resources) == 0 {
+		allErrs = append(allErrs, field.Invalid(templatePath, "Resources", "template must declare at least one resource"))
+	}
+	for _, name := range sortedKeys(resources) {
+		resource, ok := resources[name].(map[string]interface{})
+		if !ok {
+			allErrs = append(allErrs, field.Invalid(templatePath, name, "resource must be a mapping"))
+			continue
+		}
+		if resourceType, ok := resource["Type"].(string); !ok || resourceType == "" {
+			allErrs = append(allErrs, field.Invalid(templatePath, name, "resource must declare a Type"))
+		}
+	}
+
+	parameters, ok := template["Parameters"].(map[string]interface{})
+	if _, declared := template["Parameters"]; declared && !ok {
+		allErrs = append(allErrs, field.Invalid(templatePath, "Parameters", "Parameters must be a mapping"))
+	}
+
+	parametersPath := field.NewPath("spec").Child("parameters")
+	for _, key := range sortedKeys(r.Spec.Parameters) {
+		if _, ok := parameters[key]; !ok {
+			allErrs = append(allErrs, field.NotSupported(parametersPath.Key(key), key, sortedKeys(parameters)))
+		}
+	}
+	for _, key := range sortedKeys(parameters) {
+		parameter, ok := parameters[key].(map[string]interface{})
+		if !ok {
+			allErrs = append(allErrs, field.Invalid(templatePath, key, "parameter must be a mapping"))
+			continue
+		}
+		if _, ok := parameter["Type"]; !ok {
+			allErrs = append(allErrs, field.Invalid(templatePath, key, "parameter must declare a Type"))
+		}
+		_, hasDefault := parameter["Default"]
+		_, supplied := r.Spec.Parameters[key]
+		if !hasDefault && !supplied {
+			allErrs = append(allErrs, field.Required(parametersPath.Key(key), "parameter has no default value in the template"))
+		}
+	}
+
+	return allErrs
+}
+
+// sortedKeys returns the keys of a map in a stable order so that errors are reported deterministically.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch typed := m.(type) {
+	case map[string]interface{}:
+		for k := range typed {
+			keys = append(keys, k)
+		}
+	case map[string]string:
+		for k := range typed {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,258 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const webhookTemplate = `
+AWSTemplateFormatVersion: 2010-09-09
+Parameters:
+  BucketName:
+    Type: String
+  Versioning:
+    Type: String
+    Default: Suspended
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Ref BucketName
+Outputs:
+  BucketArn:
+    Value: !GetAtt Bucket.Arn
+`
+
+func webhookStack(mutate func(*Stack)) *Stack {
+	stack := &Stack{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bucket"},
+		Spec: StackSpec{
+			Template:   webhookTemplate,
+			Parameters: map[string]string{"BucketName": "my-bucket"},
+		},
+	}
+	if mutate != nil {
+		mutate(stack)
+	}
+	return stack
+}
+
+func TestValidateCreate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		mutate  func(*Stack)
+		wantErr bool
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "quoted version",
+			mutate: func(s *Stack) {
+				s.Spec.Template = `{"AWSTemplateFormatVersion": "2010-09-09", "Resources": {"Bucket": {"Type": "AWS::S3::Bucket"}}}`
+				s.Spec.Parameters = nil
+			},
+		},
+		{
+			name: "template object",
+			mutate: func(s *Stack) {
+				s.Spec.Template = ""
+				s.Spec.TemplateObject = &runtime.RawExtension{Raw: []byte(`{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket"}}}`)}
+				s.Spec.Parameters = nil
+			},
+		},
+		{
+			name: "template and template object",
+			mutate: func(s *Stack) {
+				s.Spec.TemplateObject = &runtime.RawExtension{Raw: []byte(`{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket"}}}`)}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unparsable template",
+			mutate:  func(s *Stack) { s.Spec.Template = "Resources: [" },
+			wantErr: true,
+		},
+		{
+			name: "wrong version",
+			mutate: func(s *Stack) {
+				s.Spec.Template = "AWSTemplateFormatVersion: 2012-01-01\nResources:\n  Bucket:\n    Type: AWS::S3::Bucket\n"
+				s.Spec.Parameters = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown section",
+			mutate: func(s *Stack) {
+				s.Spec.Template = "Resource:\n  Bucket:\n    Type: AWS::S3::Bucket\n"
+				s.Spec.Parameters = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "no resources",
+			mutate: func(s *Stack) {
+				s.Spec.Template = "Description: empty\n"
+				s.Spec.Parameters = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "resource without type",
+			mutate: func(s *Stack) {
+				s.Spec.Template = "Resources:\n  Bucket:\n    Properties: {}\n"
+				s.Spec.Parameters = nil
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unknown parameter",
+			mutate:  func(s *Stack) { s.Spec.Parameters["Unknown"] = "value" },
+			wantErr: true,
+		},
+		{
+			name:    "missing required parameter",
+			mutate:  func(s *Stack) { s.Spec.Parameters = nil },
+			wantErr: true,
+		},
+		{
+			name: "rendered template",
+			mutate: func(s *Stack) {
+				s.Spec.RenderTemplate = true
+				s.Spec.Template = "Resources:\n  Bucket:\n    Type: {{ .ResourceType }}\n"
+				s.Spec.Parameters = map[string]string{"BucketName": "{{ .Region }}-bucket"}
+			},
+		},
+		{
+			name: "invalid go template",
+			mutate: func(s *Stack) {
+				s.Spec.RenderTemplate = true
+				s.Spec.Parameters["BucketName"] = "{{ .Region "
+			},
+			wantErr: true,
+		},
+		{
+			name:   "poll interval",
+			mutate: func(s *Stack) { s.Annotations = map[string]string{PollIntervalAnnotation: "30s"} },
+		},
+		{
+			name:    "invalid poll interval",
+			mutate:  func(s *Stack) { s.Annotations = map[string]string{PollIntervalAnnotation: "-1s"} },
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhookStack(tt.mutate).ValidateCreate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	invalid := func(s *Stack) { s.Spec.Parameters["Unknown"] = "value" }
+
+	for _, tt := range []struct {
+		name    string
+		old     func(*Stack)
+		mutate  func(*Stack)
+		wantErr bool
+	}{
+		{
+			name:   "valid spec change",
+			mutate: func(s *Stack) { s.Spec.Parameters["Versioning"] = "Enabled" },
+		},
+		{
+			name:    "invalid spec change",
+			mutate:  invalid,
+			wantErr: true,
+		},
+		{
+			name: "metadata change of an invalid Stack",
+			old:  invalid,
+			mutate: func(s *Stack) {
+				invalid(s)
+				s.Finalizers = []string{"cloudformation.linki.space/finalizer"}
+				s.Annotations = map[string]string{PausedAnnotation: "true"}
+			},
+		},
+		{
+			name: "invalid annotation change",
+			mutate: func(s *Stack) {
+				s.Annotations = map[string]string{PollIntervalAnnotation: "often"}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "stack name change",
+			mutate:  func(s *Stack) { s.Spec.StackName = "other" },
+			wantErr: true,
+		},
+		{
+			name:    "operator class change",
+			old:     func(s *Stack) { s.Spec.OperatorClass = "sandbox" },
+			mutate:  func(s *Stack) { s.Spec.OperatorClass = "production" },
+			wantErr: true,
+		},
+		{
+			name:    "operator class added",
+			mutate:  func(s *Stack) { s.Annotations = map[string]string{OperatorClassAnnotation: "sandbox"} },
+			wantErr: true,
+		},
+		{
+			name:    "operator class removed",
+			old:     func(s *Stack) { s.Annotations = map[string]string{OperatorClassAnnotation: "sandbox"} },
+			wantErr: true,
+		},
+		{
+			name: "operator class moved from the annotation to the spec",
+			old:  func(s *Stack) { s.Annotations = map[string]string{OperatorClassAnnotation: "sandbox"} },
+			mutate: func(s *Stack) {
+				s.Annotations = map[string]string{OperatorClassAnnotation: "sandbox"}
+				s.Spec.OperatorClass = "sandbox"
+			},
+		},
+		{
+			name: "deleted Stack",
+			mutate: func(s *Stack) {
+				invalid(s)
+				s.DeletionTimestamp = &metav1.Time{}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhookStack(tt.mutate).ValidateUpdate(webhookStack(tt.old))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Retry modes of the AWS clients.
+const (
+	// RetryModeStandard retries with an exponential backoff and keeps the rate limit fixed.
+	RetryModeStandard = "standard"
+	// RetryModeAdaptiveRateLimit retries like RetryModeStandard and lowers the rate limit shared by all requests of
+	// an account and region whenever a request is throttled. It isn't the adaptive retry mode of the AWS SDKs.
+	RetryModeAdaptiveRateLimit = "adaptive-rate-limit"
+)
+
+// AWSOptions configures how the operator reaches the AWS APIs, e.g. through VPC endpoints or a proxy.
+type AWSOptions struct {
+	// Region of the AWS APIs, the SDK's default region is used if empty.
+	Region string
+	// EndpointURL replaces the endpoints of all AWS APIs, e.g. to use a local emulator.
+	EndpointURL string
+	// CloudFormationEndpointURL replaces the CloudFormation endpoint, it takes precedence over EndpointURL.
+	CloudFormationEndpointURL string
+	// STSEndpointURL replaces the STS endpoint, it takes precedence over EndpointURL.
+	STSEndpointURL string
+	// UseFIPSEndpoint selects the FIPS 140-2 endpoints of CloudFormation and STS.
+	UseFIPSEndpoint bool
+	// UseDualStackEndpoint selects the endpoints of CloudFormation and STS reachable by IPv4 and IPv6.
+	UseDualStackEndpoint bool
+	// HTTPProxy is the URL of the proxy for all requests, HTTPS_PROXY and friends are used if empty.
+	HTTPProxy string
+	// CABundle is a file of PEM encoded certificates trusted instead of the system's.
+	CABundle string
+	// RetryMode is either RetryModeStandard or RetryModeAdaptiveRateLimit.
+	RetryMode string
+	// MaxAttempts of a request, including retries.
+	MaxAttempts int
+	// MaxBackoff between the attempts of a request.
+	MaxBackoff time.Duration
+}
+
+// LoadOptions returns the options to load the AWS config with.
+func (o AWSOptions) LoadOptions() ([]func(*config.LoadOptions) error, error) {
+	if o.RetryMode != RetryModeStandard && o.RetryMode != RetryModeAdaptiveRateLimit {
+		return nil, fmt.Errorf("unknown retry mode %q, must be %q or %q", o.RetryMode, RetryModeStandard, RetryModeAdaptiveRateLimit)
+	}
+
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(o.Region),
+		config.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(so *retry.StandardOptions) {
+				so.MaxAttempts = o.MaxAttempts
+				so.MaxBackoff = o.MaxBackoff
+			})
+		}),
+		config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(o.ResolveEndpoint)),
+	}
+	if o.UseFIPSEndpoint {
+		options = append(options, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if o.UseDualStackEndpoint {
+		options = append(options, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if o.HTTPProxy != "" {
+		proxyURL, err := url.Parse(o.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP proxy: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	options = append(options, config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(
+		func(t *http.Transport) {
+			t.Proxy = proxy
+		})))
+
+	if o.CABundle != "" {
+		bundle, err := ioutil.ReadFile(o.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+		options = append(options, config.WithCustomCABundle(bytes.NewReader(bundle)))
+	}
+
+	return options, nil
+}
+
+// ResolveEndpoint implements aws.EndpointResolverWithOptions. It falls back to the SDK's endpoints of services
+// which aren't configured otherwise, which are the FIPS or dual-stack ones if UseFIPSEndpoint or
+// UseDualStackEndpoint are set.
+func (o AWSOptions) ResolveEndpoint(service, region string, _ ...interface{}) (aws.Endpoint, error) {
+	endpointURL := o.EndpointURL
+	switch {
+	case service == cloudformation.ServiceID && o.CloudFormationEndpointURL != "":
+		endpointURL = o.CloudFormationEndpointURL
+	case service == sts.ServiceID && o.STSEndpointURL != "":
+		endpointURL = o.STSEndpointURL
+	}
+	if endpointURL == "" {
+		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+	}
+	return aws.Endpoint{URL: endpointURL, SigningRegion: region, HostnameImmutable: true}, nil
+}
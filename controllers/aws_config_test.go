@@ -0,0 +1,139 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	"context"
+	coreerrors "errors"
+	"os"
+	"path/filepath"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// errEndpointCaptured stops a request once its endpoint was resolved.
+var errEndpointCaptured = coreerrors.New("endpoint captured")
+
+// requestEndpoint is where a request would have been sent to.
+type requestEndpoint struct {
+	Host          string
+	SigningRegion string
+}
+
+var _ = Describe("AWS config", func() {
+	// captureEndpoint returns an API option which records the endpoint of a request instead of sending it.
+	captureEndpoint := func(endpoint *requestEndpoint) func(*middleware.Stack) error {
+		return func(stack *middleware.Stack) error {
+			return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("CaptureEndpoint", func(
+				ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+			) (middleware.FinalizeOutput, middleware.Metadata, error) {
+				endpoint.Host = in.Request.(*smithyhttp.Request).URL.Host
+				endpoint.SigningRegion = awsmiddleware.GetSigningRegion(ctx)
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, errEndpointCaptured
+			}), middleware.After)
+		}
+	}
+
+	// endpoints returns where CloudFormation and STS requests are sent to with the given options.
+	endpoints := func(options AWSOptions) (requestEndpoint, requestEndpoint) {
+		options.RetryMode = RetryModeStandard
+		options.MaxAttempts = 1
+		loadOptions, err := options.LoadOptions()
+		Expect(err).NotTo(HaveOccurred())
+		loadOptions = append(loadOptions,
+			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("key", "secret", "")),
+			// Keep the shared config of the environment out of the test.
+			config.WithSharedConfigFiles([]string{filepath.Join(os.TempDir(), "missing-aws-config")}),
+			config.WithSharedCredentialsFiles([]string{filepath.Join(os.TempDir(), "missing-aws-credentials")}),
+		)
+		cfg, err := config.LoadDefaultConfig(context.Background(), loadOptions...)
+		Expect(err).NotTo(HaveOccurred())
+
+		var cloudFormationEndpoint, stsEndpoint requestEndpoint
+		_, err = cloudformation.NewFromConfig(cfg, func(o *cloudformation.Options) {
+			o.APIOptions = append(o.APIOptions, captureEndpoint(&cloudFormationEndpoint))
+		}).DescribeStacks(context.Background(), &cloudformation.DescribeStacksInput{})
+		Expect(coreerrors.Is(err, errEndpointCaptured)).To(BeTrue(), "%v", err)
+		_, err = sts.NewFromConfig(cfg, func(o *sts.Options) {
+			o.APIOptions = append(o.APIOptions, captureEndpoint(&stsEndpoint))
+		}).GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+		Expect(coreerrors.Is(err, errEndpointCaptured)).To(BeTrue(), "%v", err)
+		return cloudFormationEndpoint, stsEndpoint
+	}
+
+	table.DescribeTable("resolves the endpoints of CloudFormation and STS",
+		func(options AWSOptions, cloudFormationEndpoint, stsEndpoint requestEndpoint) {
+			cloudFormation, sts := endpoints(options)
+			Expect(cloudFormation).To(Equal(cloudFormationEndpoint))
+			Expect(sts).To(Equal(stsEndpoint))
+		},
+		table.Entry("regional endpoints",
+			AWSOptions{Region: "eu-central-1"},
+			requestEndpoint{"cloudformation.eu-central-1.amazonaws.com", "eu-central-1"},
+			requestEndpoint{"sts.eu-central-1.amazonaws.com", "eu-central-1"}),
+		table.Entry("FIPS endpoints",
+			AWSOptions{Region: "us-east-2", UseFIPSEndpoint: true},
+			requestEndpoint{"cloudformation-fips.us-east-2.amazonaws.com", "us-east-2"},
+			requestEndpoint{"sts-fips.us-east-2.amazonaws.com", "us-east-2"}),
+		table.Entry("dual-stack endpoints",
+			AWSOptions{Region: "eu-central-1", UseDualStackEndpoint: true},
+			requestEndpoint{"cloudformation.eu-central-1.api.aws", "eu-central-1"},
+			requestEndpoint{"sts.eu-central-1.api.aws", "eu-central-1"}),
+		table.Entry("custom endpoint URL",
+			AWSOptions{Region: "eu-central-1", EndpointURL: "http://localhost:4566"},
+			requestEndpoint{"localhost:4566", "eu-central-1"},
+			requestEndpoint{"localhost:4566", "eu-central-1"}),
+		table.Entry("custom endpoint URLs per service",
+			AWSOptions{
+				Region:                    "eu-central-1",
+				EndpointURL:               "http://localhost:4566",
+				CloudFormationEndpointURL: "https://vpce-1.cloudformation.eu-central-1.vpce.amazonaws.com",
+			},
+			requestEndpoint{"vpce-1.cloudformation.eu-central-1.vpce.amazonaws.com", "eu-central-1"},
+			requestEndpoint{"localhost:4566", "eu-central-1"}),
+		table.Entry("custom endpoint URL instead of FIPS endpoints",
+			AWSOptions{Region: "us-east-2", STSEndpointURL: "https://sts.example.com", UseFIPSEndpoint: true},
+			requestEndpoint{"cloudformation-fips.us-east-2.amazonaws.com", "us-east-2"},
+			requestEndpoint{"sts.example.com", "us-east-2"}),
+		table.Entry("region override of a custom endpoint URL",
+			AWSOptions{Region: "ap-southeast-2", EndpointURL: "http://localhost:4566"},
+			requestEndpoint{"localhost:4566", "ap-southeast-2"},
+			requestEndpoint{"localhost:4566", "ap-southeast-2"}),
+	)
+
+	It("rejects unknown retry modes", func() {
+		_, err := AWSOptions{RetryMode: "adaptive"}.LoadOptions()
+		Expect(err).To(HaveOccurred())
+	})
+})
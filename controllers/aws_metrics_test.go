@@ -0,0 +1,100 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/smithy-go/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const throttlingResponse = `<ErrorResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>Throttling</Code>
+    <Message>Rate exceeded</Message>
+  </Error>
+  <RequestId>1</RequestId>
+</ErrorResponse>`
+
+var _ = Describe("AWS metrics", func() {
+	// sampleCount returns the number of observations of a histogram.
+	sampleCount := func(observer prometheus.Observer) uint64 {
+		metric := &dto.Metric{}
+		Expect(observer.(prometheus.Histogram).Write(metric)).To(Succeed())
+		return metric.GetHistogram().GetSampleCount()
+	}
+
+	It("counts requests by operation and error code and measures their latency", func() {
+		throttled := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/xml")
+			if throttled {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(throttlingResponse))
+				return
+			}
+			_, _ = w.Write([]byte(describeStacksResponse))
+		}))
+		defer server.Close()
+
+		client := cloudformation.New(cloudformation.Options{
+			Region:           "us-east-1",
+			Credentials:      credentials.NewStaticCredentialsProvider("key", "secret", ""),
+			EndpointResolver: cloudformation.EndpointResolverFromURL(server.URL),
+			Retryer:          aws.NopRetryer{},
+			APIOptions:       []func(*middleware.Stack) error{AddAPIMetrics},
+		})
+		succeeded := awsRequests.WithLabelValues("DescribeStacks", "")
+		failed := awsRequests.WithLabelValues("DescribeStacks", "Throttling")
+		before := testutil.ToFloat64(succeeded)
+		observations := sampleCount(awsRequestDuration.WithLabelValues("DescribeStacks"))
+
+		_, err := client.DescribeStacks(context.Background(), &cloudformation.DescribeStacksInput{StackName: aws.String("my-stack")})
+		Expect(err).NotTo(HaveOccurred())
+		throttled = true
+		_, err = client.DescribeStacks(context.Background(), &cloudformation.DescribeStacksInput{StackName: aws.String("my-stack")})
+		Expect(err).To(HaveOccurred())
+
+		Expect(testutil.ToFloat64(succeeded)).To(Equal(before + 1))
+		Expect(testutil.ToFloat64(failed)).To(Equal(float64(1)))
+		Expect(sampleCount(awsRequestDuration.WithLabelValues("DescribeStacks"))).To(Equal(observations + 2))
+	})
+
+	It("tells requests without an API error apart", func() {
+		Expect(errorCode(nil)).To(BeEmpty())
+		Expect(errorCode(context.Canceled)).To(Equal("Unknown"))
+	})
+})
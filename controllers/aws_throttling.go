@@ -0,0 +1,171 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// A throttled request halves the rate, which never drops below minRateFraction of the configured rate.
+	minRateFraction = 0.1
+	// Every successful request raises the rate by this fraction of the configured rate.
+	rateRecoveryFraction = 0.02
+)
+
+var apiRateLimiters = struct {
+	sync.Mutex
+	byAccountRegion map[string]*APIRateLimiter
+}{byAccountRegion: map[string]*APIRateLimiter{}}
+
+// APIRateLimiter is a token bucket shared by all requests to the AWS APIs of one account and region, so that
+// a burst of reconciles doesn't get everything throttled at once. Unless it is fixed, it halves its rate
+// whenever a request is throttled anyway and recovers slowly while requests succeed.
+type APIRateLimiter struct {
+	account string
+	region  string
+	maxRate rate.Limit
+
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	fixed   bool
+}
+
+// APIRateLimiterFor returns the limiter of the given account and region, creating it with the given
+// requests per second and burst if needed.
+func APIRateLimiterFor(account, region string, qps float64, burst int) *APIRateLimiter {
+	apiRateLimiters.Lock()
+	defer apiRateLimiters.Unlock()
+
+	key := account + "/" + region
+	if limiter, ok := apiRateLimiters.byAccountRegion[key]; ok {
+		return limiter
+	}
+
+	limiter := &APIRateLimiter{
+		account: account,
+		region:  region,
+		maxRate: rate.Limit(qps),
+		limiter: rate.NewLimiter(rate.Limit(qps), burst),
+	}
+	awsRateLimit.WithLabelValues(account, region).Set(qps)
+	apiRateLimiters.byAccountRegion[key] = limiter
+	return limiter
+}
+
+// SetAdaptive sets whether the limiter adapts its rate to throttling, it does by default.
+func (l *APIRateLimiter) SetAdaptive(adaptive bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.fixed = !adaptive
+	if l.fixed && l.limiter.Limit() != l.maxRate {
+		l.limiter.SetLimit(l.maxRate)
+		awsRateLimit.WithLabelValues(l.account, l.region).Set(float64(l.maxRate))
+	}
+}
+
+// AddToStack adds the limiter to the middleware stack of an AWS client, so that every attempt of a request,
+// including retries, waits for a token. Add it to the APIOptions of the client.
+func (l *APIRateLimiter) AddToStack(stack *middleware.Stack) error {
+	if err := stack.Finalize.Insert(retryCounter{}, "Retry", middleware.Before); err != nil {
+		return err
+	}
+	return stack.Finalize.Insert(l, "Retry", middleware.After)
+}
+
+// ID implements middleware.FinalizeMiddleware.
+func (l *APIRateLimiter) ID() string {
+	return "APIRateLimiter"
+}
+
+// HandleFinalize implements middleware.FinalizeMiddleware.
+func (l *APIRateLimiter) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (
+	out middleware.FinalizeOutput, metadata middleware.Metadata, err error) {
+	if err := l.limiter.Wait(ctx); err != nil {
+		return out, metadata, err
+	}
+
+	if attempts, ok := ctx.Value(attemptsKey{}).(*int); ok {
+		*attempts++
+	}
+
+	out, metadata, err = next.HandleFinalize(ctx, in)
+	switch {
+	case err == nil:
+		l.adjust(l.limiter.Limit() + l.maxRate*rateRecoveryFraction)
+	case classifyError(err) == ErrorClassThrottling:
+		awsThrottledRequests.WithLabelValues(awsmiddleware.GetOperationName(ctx)).Inc()
+		l.adjust(l.limiter.Limit() / 2)
+	}
+	return out, metadata, err
+}
+
+// adjust sets the rate of the limiter within its bounds.
+func (l *APIRateLimiter) adjust(limit rate.Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.fixed {
+		return
+	}
+	if limit > l.maxRate {
+		limit = l.maxRate
+	}
+	if limit < l.maxRate*minRateFraction {
+		limit = l.maxRate * minRateFraction
+	}
+	if limit != l.limiter.Limit() {
+		l.limiter.SetLimit(limit)
+		awsRateLimit.WithLabelValues(l.account, l.region).Set(float64(limit))
+	}
+}
+
+type attemptsKey struct{}
+
+// retryCounter counts the retries of a request, it runs before the retry middleware.
+type retryCounter struct{}
+
+// ID implements middleware.FinalizeMiddleware.
+func (retryCounter) ID() string {
+	return "RetryCounter"
+}
+
+// HandleFinalize implements middleware.FinalizeMiddleware.
+func (retryCounter) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (
+	middleware.FinalizeOutput, middleware.Metadata, error) {
+	attempts := 0
+	out, metadata, err := next.HandleFinalize(context.WithValue(ctx, attemptsKey{}, &attempts), in)
+	if attempts > 1 {
+		awsRetriedRequests.WithLabelValues(awsmiddleware.GetOperationName(ctx)).Add(float64(attempts - 1))
+	}
+	return out, metadata, err
+}
@@ -0,0 +1,94 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	coreerrors "errors"
+	"strings"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+)
+
+// ErrorClass tells apart the errors returned by CloudFormation that the operator handles differently.
+type ErrorClass string
+
+const (
+	// ErrorClassUnknown is any error that isn't classified, e.g. a network error.
+	ErrorClassUnknown ErrorClass = ""
+	// ErrorClassNotFound is returned when the requested stack doesn't exist.
+	ErrorClassNotFound ErrorClass = "NotFound"
+	// ErrorClassNoUpdates is returned when an update doesn't change the stack.
+	ErrorClassNoUpdates ErrorClass = "NoUpdates"
+	// ErrorClassValidation is returned for invalid requests, e.g. an invalid template or missing parameters.
+	ErrorClassValidation ErrorClass = "ValidationError"
+	// ErrorClassThrottling is returned when the request was rate limited.
+	ErrorClassThrottling ErrorClass = "Throttling"
+	// ErrorClassAlreadyExists is returned when creating a stack that exists already.
+	ErrorClassAlreadyExists ErrorClass = "AlreadyExists"
+	// ErrorClassInsufficientCapabilities is returned when the template needs capabilities that weren't granted.
+	ErrorClassInsufficientCapabilities ErrorClass = "InsufficientCapabilities"
+	// ErrorClassTokenAlreadyExists is returned when a request with the same client request token was made already.
+	ErrorClassTokenAlreadyExists ErrorClass = "TokenAlreadyExists"
+)
+
+// ClassifyError returns the class of an error returned by CloudFormation based on its API error code.
+// CloudFormation reports missing stacks and empty updates as ValidationErrors, which are only told apart
+// by their message.
+func (cf *CloudFormationHelper) ClassifyError(err error) ErrorClass {
+	return classifyError(err)
+}
+
+func classifyError(err error) ErrorClass {
+	var apiErr smithy.APIError
+	if !coreerrors.As(err, &apiErr) {
+		return ErrorClassUnknown
+	}
+
+	switch apiErr.ErrorCode() {
+	case "ValidationError":
+		// CloudFormation has no error codes of their own for these, see
+		// https://docs.aws.amazon.com/AWSCloudFormation/latest/APIReference/CommonErrors.html,
+		// and its messages have been stable for years.
+		message := apiErr.ErrorMessage()
+		switch {
+		case strings.Contains(message, "does not exist"):
+			return ErrorClassNotFound
+		case strings.Contains(message, "No updates are to be performed"):
+			return ErrorClassNoUpdates
+		}
+		return ErrorClassValidation
+	case "Throttling", "ThrottlingException", "RequestLimitExceeded":
+		return ErrorClassThrottling
+	case (&cfTypes.AlreadyExistsException{}).ErrorCode():
+		return ErrorClassAlreadyExists
+	case (&cfTypes.InsufficientCapabilitiesException{}).ErrorCode():
+		return ErrorClassInsufficientCapabilities
+	case (&cfTypes.TokenAlreadyExistsException{}).ErrorCode():
+		return ErrorClassTokenAlreadyExists
+	}
+	return ErrorClassUnknown
+}
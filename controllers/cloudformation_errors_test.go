@@ -0,0 +1,62 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	coreerrors "errors"
+	"fmt"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudFormation errors", func() {
+	validationError := func(message string) error {
+		return &smithy.GenericAPIError{Code: "ValidationError", Message: message, Fault: smithy.FaultClient}
+	}
+
+	table.DescribeTable("classifies errors by their API error code",
+		func(err error, class ErrorClass) {
+			Expect(classifyError(err)).To(Equal(class))
+		},
+		table.Entry("missing stack", validationError("Stack with id my-stack does not exist"), ErrorClassNotFound),
+		table.Entry("empty update", validationError("No updates are to be performed."), ErrorClassNoUpdates),
+		table.Entry("invalid template", validationError("Template format error: unsupported structure."), ErrorClassValidation),
+		table.Entry("throttling", &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}, ErrorClassThrottling),
+		table.Entry("throttling exception", &smithy.GenericAPIError{Code: "ThrottlingException"}, ErrorClassThrottling),
+		table.Entry("request limit exceeded", &smithy.GenericAPIError{Code: "RequestLimitExceeded"}, ErrorClassThrottling),
+		table.Entry("existing stack", &cfTypes.AlreadyExistsException{}, ErrorClassAlreadyExists),
+		table.Entry("missing capabilities", &cfTypes.InsufficientCapabilitiesException{}, ErrorClassInsufficientCapabilities),
+		table.Entry("reused request token", &cfTypes.TokenAlreadyExistsException{}, ErrorClassTokenAlreadyExists),
+		table.Entry("wrapped error", fmt.Errorf("creating stack: %w", &cfTypes.AlreadyExistsException{}), ErrorClassAlreadyExists),
+		table.Entry("unknown error code", &smithy.GenericAPIError{Code: "InternalFailure"}, ErrorClassUnknown),
+		table.Entry("non-API error", coreerrors.New("connection reset by peer"), ErrorClassUnknown),
+		table.Entry("no error", nil, ErrorClassUnknown),
+	)
+})
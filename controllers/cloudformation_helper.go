@@ -28,19 +28,57 @@ package controllers
 import (
 	"context"
 	coreerrors "errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
 	"strings"
+	"sync"
+	"time"
 )
 
 var (
 	ErrStackNotFound = coreerrors.New("stack not found")
 )
 
+// CloudFormationAPI is the part of the CloudFormation API the operator uses. It's implemented by
+// *cloudformation.Client.
+type CloudFormationAPI interface {
+	CreateStack(ctx context.Context, params *cloudformation.CreateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateStackOutput, error)
+	UpdateStack(ctx context.Context, params *cloudformation.UpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateStackOutput, error)
+	DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error)
+	ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error)
+	CancelUpdateStack(ctx context.Context, params *cloudformation.CancelUpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CancelUpdateStackOutput, error)
+	DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+	DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error)
+	ListStackResources(ctx context.Context, params *cloudformation.ListStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error)
+}
+
+var _ CloudFormationAPI = &cloudformation.Client{}
+
 type CloudFormationHelper struct {
-	CloudFormation *cloudformation.Client
+	CloudFormation CloudFormationAPI
+	// Cache is used to look up stacks if set.
+	Cache *StackCache
+
+	failuresMu sync.Mutex
+	// failures holds the last failure looked up per stack name.
+	failures map[string]stackFailure
+}
+
+// stackFailure is why a stack failed, valid as long as the stack doesn't change.
+type stackFailure struct {
+	stackID     string
+	status      cfTypes.StackStatus
+	lastUpdated time.Time
+	reason      string
+	failedAt    time.Time
+}
+
+func (f stackFailure) matches(stack *cfTypes.Stack) bool {
+	return f.stackID == aws.ToString(stack.StackId) && f.status == stack.StackStatus &&
+		f.lastUpdated.Equal(aws.ToTime(stack.LastUpdatedTime))
 }
 
 // Identify if the follower considers the state identified as terminal.
@@ -59,14 +97,22 @@ func (cf *CloudFormationHelper) GetStack(ctx context.Context, instance *cloudfor
 	// Must use the stack ID to get details/finalization for deleted stacks
 	name := instance.Status.StackID
 	if name == "" {
-		name = instance.Name
+		name = instance.GetStackName()
 	}
+	if cf.Cache != nil {
+		stack, err := cf.Cache.GetStack(ctx, name)
+		if err != nil && cf.ClassifyError(err) == ErrorClassNotFound {
+			return nil, ErrStackNotFound
+		}
+		return stack, err
+	}
+
 	resp, err := cf.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
 		NextToken: nil,
 		StackName: aws.String(name),
 	})
 	if err != nil {
-		if strings.Contains(err.Error(), "does not exist") {
+		if cf.ClassifyError(err) == ErrorClassNotFound {
 			return nil, ErrStackNotFound
 		}
 		return nil, err
@@ -78,7 +124,37 @@ func (cf *CloudFormationHelper) GetStack(ctx context.Context, instance *cloudfor
 	return &resp.Stacks[0], nil
 }
 
-func (cf *CloudFormationHelper) GetStackResources(ctx context.Context, stackId string) ([]cloudformationv1alpha1.StackResource, error) {
+// InvalidateStack makes the next lookup of the given Stack's CloudFormation stack describe it again.
+// It must be called after changing the stack.
+func (cf *CloudFormationHelper) InvalidateStack(instance *cloudformationv1alpha1.Stack) {
+	if cf.Cache != nil {
+		cf.Cache.Invalidate(instance.Status.StackID, instance.GetStackName())
+	}
+}
+
+// GetStackResources returns the resources of the given stack. With a Cache the resources of a stack in a
+// terminal state are only listed again once the stack was updated or its status changed, while those of a
+// stack in progress are listed on every call.
+func (cf *CloudFormationHelper) GetStackResources(ctx context.Context, stack *cfTypes.Stack) ([]cloudformationv1alpha1.StackResource, error) {
+	cached := cf.Cache != nil && cf.StackInTerminalState(stack.StackStatus)
+	if cached {
+		if resources, ok := cf.Cache.getResources(stack); ok {
+			return resources, nil
+		}
+	}
+
+	resources, err := cf.listStackResources(ctx, *stack.StackId)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached {
+		cf.Cache.storeResources(stack, resources)
+	}
+	return resources, nil
+}
+
+func (cf *CloudFormationHelper) listStackResources(ctx context.Context, stackId string) ([]cloudformationv1alpha1.StackResource, error) {
 
 	var next *string
 	next = nil
@@ -121,3 +197,96 @@ func (cf *CloudFormationHelper) GetStackResources(ctx context.Context, stackId s
 
 	return toReturn, nil
 }
+
+// GetStackFinishedTime returns when the stack reached its current status, i.e. the time of the newest event of
+// the stack itself with that status. It's zero if there's no such event on the first page of events.
+func (cf *CloudFormationHelper) GetStackFinishedTime(ctx context.Context, stack *cfTypes.Stack) (time.Time, error) {
+	resp, err := cf.CloudFormation.DescribeStackEvents(ctx, &cloudformation.DescribeStackEventsInput{
+		StackName: stack.StackId,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, e := range resp.StackEvents {
+		if aws.ToString(e.ResourceType) == "AWS::CloudFormation::Stack" &&
+			aws.ToString(e.PhysicalResourceId) == aws.ToString(stack.StackId) &&
+			string(e.ResourceStatus) == string(stack.StackStatus) {
+			return aws.ToTime(e.Timestamp), nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// GetStackFailure returns why the creation of a stack failed, i.e. the reason reported for the first resource that
+// failed to be created, together with the time the stack reached its failed state. The events of a stack are
+// only paged through again once the stack changed.
+func (cf *CloudFormationHelper) GetStackFailure(ctx context.Context, stack *cfTypes.Stack) (string, time.Time, error) {
+	name := aws.ToString(stack.StackName)
+	cf.failuresMu.Lock()
+	failure, ok := cf.failures[name]
+	cf.failuresMu.Unlock()
+	if ok && failure.matches(stack) {
+		return failure.reason, failure.failedAt, nil
+	}
+
+	reason, failedAt, err := cf.describeStackFailure(ctx, stack)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	cf.failuresMu.Lock()
+	defer cf.failuresMu.Unlock()
+	if cf.failures == nil {
+		cf.failures = map[string]stackFailure{}
+	}
+	cf.failures[name] = stackFailure{
+		stackID:     aws.ToString(stack.StackId),
+		status:      stack.StackStatus,
+		lastUpdated: aws.ToTime(stack.LastUpdatedTime),
+		reason:      reason,
+		failedAt:    failedAt,
+	}
+	return reason, failedAt, nil
+}
+
+// describeStackFailure pages through the events of a stack to find out why and when it failed.
+func (cf *CloudFormationHelper) describeStackFailure(ctx context.Context, stack *cfTypes.Stack) (string, time.Time, error) {
+	reason := ""
+	if stack.StackStatusReason != nil {
+		reason = *stack.StackStatusReason
+	}
+	failedAt := *stack.CreationTime
+
+	var next *string
+	first := true
+
+	for {
+		resp, err := cf.CloudFormation.DescribeStackEvents(ctx, &cloudformation.DescribeStackEventsInput{
+			NextToken: next,
+			StackName: stack.StackId,
+		})
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		// Events are returned newest first, so the last matching event is the original failure.
+		for _, e := range resp.StackEvents {
+			if first && e.Timestamp != nil {
+				failedAt = *e.Timestamp
+				first = false
+			}
+			if e.ResourceStatus == cfTypes.ResourceStatusCreateFailed && e.ResourceStatusReason != nil &&
+				*e.ResourceStatusReason != "Resource creation cancelled" && aws.ToString(e.ResourceType) != "AWS::CloudFormation::Stack" {
+				reason = fmt.Sprintf("%s: %s", aws.ToString(e.LogicalResourceId), *e.ResourceStatusReason)
+			}
+		}
+
+		next = resp.NextToken
+		if next == nil {
+			break
+		}
+	}
+
+	return reason, failedAt, nil
+}
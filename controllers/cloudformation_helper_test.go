@@ -0,0 +1,107 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/linki/cloudformation-operator/controllers/cloudformationfake"
+)
+
+var _ = Describe("CloudFormation helper", func() {
+	ctx := context.Background()
+
+	It("tells when a stack reached its status", func() {
+		fake := cloudformationfake.New()
+		now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		fake.Now = func() time.Time { return now }
+		helper := &CloudFormationHelper{CloudFormation: fake}
+
+		_, err := fake.CreateStack(ctx, &cloudformation.CreateStackInput{
+			StackName:    aws.String("finished"),
+			TemplateBody: aws.String(bucketTemplate),
+			Parameters:   []cfTypes.Parameter{{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("finished")}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		now = now.Add(2 * time.Minute)
+		fake.Tick()
+		resp, err := fake.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String("finished")})
+		Expect(err).NotTo(HaveOccurred())
+		stack := resp.Stacks[0]
+		Expect(stack.StackStatus).To(Equal(cfTypes.StackStatusCreateComplete))
+		now = now.Add(time.Hour)
+
+		finished, err := helper.GetStackFinishedTime(ctx, &stack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(finished).To(Equal(aws.ToTime(stack.CreationTime).Add(2 * time.Minute)))
+	})
+
+	It("only pages through the events of a failed stack again once it changed", func() {
+		fake := cloudformationfake.New()
+		fake.PageSize = 1
+		helper := &CloudFormationHelper{CloudFormation: fake}
+
+		_, err := fake.CreateStack(ctx, &cloudformation.CreateStackInput{
+			StackName:    aws.String("failed"),
+			TemplateBody: aws.String(failingTemplate),
+			Parameters:   []cfTypes.Parameter{{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("failed")}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		describe := func() *cfTypes.Stack {
+			resp, err := fake.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String("failed")})
+			Expect(err).NotTo(HaveOccurred())
+			return &resp.Stacks[0]
+		}
+		for describe().StackStatus != cfTypes.StackStatusRollbackComplete {
+			fake.Tick()
+		}
+
+		stack := describe()
+		reason, failedAt, err := helper.GetStackFailure(ctx, stack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reason).To(Equal("Failure: Bucket policy is invalid"))
+		calls := fake.Calls("DescribeStackEvents")
+		Expect(calls).To(BeNumerically(">", 1))
+
+		cached, cachedAt, err := helper.GetStackFailure(ctx, describe())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cached).To(Equal(reason))
+		Expect(cachedAt).To(Equal(failedAt))
+		Expect(fake.Calls("DescribeStackEvents")).To(Equal(calls))
+
+		_, err = fake.DeleteStack(ctx, &cloudformation.DeleteStackInput{StackName: stack.StackId})
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = helper.GetStackFailure(ctx, describe())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.Calls("DescribeStackEvents")).To(BeNumerically(">", calls))
+	})
+})
@@ -0,0 +1,781 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package cloudformationfake provides a stateful in-memory fake of the CloudFormation API for tests.
+//
+// Stacks go through the same states as in CloudFormation. Creating, updating or deleting a stack puts it
+// into the matching IN_PROGRESS state, and every call to Tick moves all stacks in progress on by one step
+// until they reach a terminal state. An operation fails if the template contains a resource of type
+// Fake::Failure, whose optional Reason property is reported as the reason of the failure. Its optional
+// FailRollback property makes the rollback of a failed update fail as well. A creation fails, too, if it's
+// still in progress after the TimeoutInMinutes it was created with, measured with Now.
+package cloudformationfake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+// FailureType is the type of the resource that makes an operation fail.
+const FailureType = "Fake::Failure"
+
+const defaultPageSize = 100
+
+// CloudFormation is an in-memory fake of the CloudFormation API. It's safe for concurrent use.
+type CloudFormation struct {
+	// Region and AccountID are used in stack IDs.
+	Region    string
+	AccountID string
+	// AutoTick moves all stacks in progress on by one step before stacks are described.
+	AutoTick bool
+	// PageSize is the number of stacks, events and resources returned per page.
+	PageSize int
+	// Now returns the current time, time.Now by default.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	stacks   []*stack
+	sequence int
+	throttle int
+	latency  time.Duration
+	calls    map[string]int
+	inFlight map[string]int
+	overlaps map[string]int
+}
+
+type stack struct {
+	cfTypes.Stack
+	body      string
+	template  map[string]interface{}
+	events    []cfTypes.StackEvent
+	resources []cfTypes.StackResourceSummary
+	// previous is the state an update rolls back to.
+	previous *stack
+}
+
+// New creates an empty fake CloudFormation.
+func New() *CloudFormation {
+	return &CloudFormation{
+		Region:    "us-east-1",
+		AccountID: "123456789012",
+		PageSize:  defaultPageSize,
+		Now:       time.Now,
+		calls:     map[string]int{},
+		inFlight:  map[string]int{},
+		overlaps:  map[string]int{},
+	}
+}
+
+// Throttle makes the next n requests fail with a Throttling error.
+func (cf *CloudFormation) Throttle(n int) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	cf.throttle = n
+}
+
+// Calls returns how often the given operation, e.g. DescribeStacks, was called.
+func (cf *CloudFormation) Calls(operation string) int {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	return cf.calls[operation]
+}
+
+// SetLatency makes every call take the given time, so that tests can observe calls for the same stack
+// overlapping.
+func (cf *CloudFormation) SetLatency(latency time.Duration) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	cf.latency = latency
+}
+
+// Overlaps returns how often a call for the stack with the given name started while another call for it
+// was still in flight.
+func (cf *CloudFormation) Overlaps(name string) int {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	return cf.overlaps[name]
+}
+
+// Stack returns the most recent stack with the given name or ID, including deleted ones.
+func (cf *CloudFormation) Stack(name string) (cfTypes.Stack, bool) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	for i := len(cf.stacks) - 1; i >= 0; i-- {
+		if aws.ToString(cf.stacks[i].StackName) == name || aws.ToString(cf.stacks[i].StackId) == name {
+			return cf.stacks[i].describe(), true
+		}
+	}
+	return cfTypes.Stack{}, false
+}
+
+// Template returns the template body of the most recent stack with the given name or ID.
+func (cf *CloudFormation) Template(name string) string {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	for i := len(cf.stacks) - 1; i >= 0; i-- {
+		if aws.ToString(cf.stacks[i].StackName) == name || aws.ToString(cf.stacks[i].StackId) == name {
+			return cf.stacks[i].body
+		}
+	}
+	return ""
+}
+
+// Tick moves all stacks in progress on by one step.
+func (cf *CloudFormation) Tick() {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	cf.tick()
+}
+
+// CreateStack implements the CreateStack operation.
+func (cf *CloudFormation) CreateStack(_ context.Context, params *cloudformation.CreateStackInput, _ ...func(*cloudformation.Options)) (*cloudformation.CreateStackOutput, error) {
+	defer cf.begin(params.StackName)()
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.call("CreateStack"); err != nil {
+		return nil, err
+	}
+
+	name := aws.ToString(params.StackName)
+	if s := cf.find(name); s != nil {
+		return nil, &cfTypes.AlreadyExistsException{Message: aws.String(fmt.Sprintf("Stack [%s] already exists", name))}
+	}
+
+	template, err := cf.validate(aws.ToString(params.TemplateBody), params.Parameters, params.Capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	cf.sequence++
+	now := cf.Now()
+	s := &stack{
+		Stack: cfTypes.Stack{
+			StackId:          aws.String(fmt.Sprintf("arn:aws:cloudformation:%s:%s:stack/%s/%08d-0000-0000-0000-000000000000", cf.Region, cf.AccountID, name, cf.sequence)),
+			StackName:        aws.String(name),
+			CreationTime:     aws.Time(now),
+			Capabilities:     params.Capabilities,
+			Parameters:       params.Parameters,
+			Tags:             params.Tags,
+			TimeoutInMinutes: params.TimeoutInMinutes,
+		},
+		body:     aws.ToString(params.TemplateBody),
+		template: template,
+	}
+	cf.stacks = append(cf.stacks, s)
+	cf.transition(s, cfTypes.StackStatusCreateInProgress, "User Initiated")
+
+	return &cloudformation.CreateStackOutput{StackId: s.StackId}, nil
+}
+
+// UpdateStack implements the UpdateStack operation.
+func (cf *CloudFormation) UpdateStack(_ context.Context, params *cloudformation.UpdateStackInput, _ ...func(*cloudformation.Options)) (*cloudformation.UpdateStackOutput, error) {
+	defer cf.begin(params.StackName)()
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.call("UpdateStack"); err != nil {
+		return nil, err
+	}
+
+	s := cf.find(aws.ToString(params.StackName))
+	if s == nil {
+		return nil, notFound(aws.ToString(params.StackName))
+	}
+	switch s.StackStatus {
+	case cfTypes.StackStatusCreateComplete, cfTypes.StackStatusUpdateComplete, cfTypes.StackStatusUpdateRollbackComplete:
+	default:
+		return nil, validationError(fmt.Sprintf("Stack:%s is in %s state and can not be updated.", aws.ToString(s.StackId), s.StackStatus))
+	}
+
+	template, err := cf.validate(aws.ToString(params.TemplateBody), params.Parameters, params.Capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.body == aws.ToString(params.TemplateBody) && reflect.DeepEqual(parameterMap(s.Parameters), parameterMap(params.Parameters)) &&
+		reflect.DeepEqual(tagMap(s.Tags), tagMap(params.Tags)) {
+		return nil, validationError("No updates are to be performed.")
+	}
+
+	previous := *s
+	s.previous = &previous
+	s.body = aws.ToString(params.TemplateBody)
+	s.template = template
+	s.Capabilities = params.Capabilities
+	s.Parameters = params.Parameters
+	s.Tags = params.Tags
+	s.LastUpdatedTime = aws.Time(cf.Now())
+	cf.transition(s, cfTypes.StackStatusUpdateInProgress, "User Initiated")
+
+	return &cloudformation.UpdateStackOutput{StackId: s.StackId}, nil
+}
+
+// DeleteStack implements the DeleteStack operation.
+func (cf *CloudFormation) DeleteStack(_ context.Context, params *cloudformation.DeleteStackInput, _ ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	defer cf.begin(params.StackName)()
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.call("DeleteStack"); err != nil {
+		return nil, err
+	}
+
+	// Deleting a stack that doesn't exist (anymore) succeeds.
+	s := cf.find(aws.ToString(params.StackName))
+	if s == nil || s.StackStatus == cfTypes.StackStatusDeleteInProgress {
+		return &cloudformation.DeleteStackOutput{}, nil
+	}
+	if strings.HasSuffix(string(s.StackStatus), "_IN_PROGRESS") {
+		return nil, validationError(fmt.Sprintf("Stack [%s] cannot be deleted while in status %s", aws.ToString(s.StackName), s.StackStatus))
+	}
+
+	s.DeletionTime = aws.Time(cf.Now())
+	cf.transition(s, cfTypes.StackStatusDeleteInProgress, "User Initiated")
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+// ContinueUpdateRollback implements the ContinueUpdateRollback operation.
+func (cf *CloudFormation) ContinueUpdateRollback(_ context.Context, params *cloudformation.ContinueUpdateRollbackInput, _ ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+	defer cf.begin(params.StackName)()
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.call("ContinueUpdateRollback"); err != nil {
+		return nil, err
+	}
+
+	s := cf.find(aws.ToString(params.StackName))
+	if s == nil {
+		return nil, notFound(aws.ToString(params.StackName))
+	}
+	if s.StackStatus != cfTypes.StackStatusUpdateRollbackFailed {
+		return nil, validationError(fmt.Sprintf("Stack %s is in %s state and can not continue the update rollback.", aws.ToString(s.StackId), s.StackStatus))
+	}
+
+	// Skipping resources or trying again lets the rollback succeed.
+	s.template = withoutFailure(s.template)
+	cf.transition(s, cfTypes.StackStatusUpdateRollbackInProgress, "")
+	return &cloudformation.ContinueUpdateRollbackOutput{}, nil
+}
+
+// CancelUpdateStack implements the CancelUpdateStack operation.
+func (cf *CloudFormation) CancelUpdateStack(_ context.Context, params *cloudformation.CancelUpdateStackInput, _ ...func(*cloudformation.Options)) (*cloudformation.CancelUpdateStackOutput, error) {
+	defer cf.begin(params.StackName)()
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.call("CancelUpdateStack"); err != nil {
+		return nil, err
+	}
+
+	s := cf.find(aws.ToString(params.StackName))
+	if s == nil {
+		return nil, notFound(aws.ToString(params.StackName))
+	}
+	if s.StackStatus != cfTypes.StackStatusUpdateInProgress {
+		return nil, validationError(fmt.Sprintf("CancelUpdateStack cannot be called from current stack status %s.", s.StackStatus))
+	}
+
+	cf.transition(s, cfTypes.StackStatusUpdateRollbackInProgress, "Stack update cancelled")
+	return &cloudformation.CancelUpdateStackOutput{}, nil
+}
+
+// DescribeStacks implements the DescribeStacks operation.
+func (cf *CloudFormation) DescribeStacks(_ context.Context, params *cloudformation.DescribeStacksInput, _ ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	defer cf.begin(params.StackName)()
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.call("DescribeStacks"); err != nil {
+		return nil, err
+	}
+	if cf.AutoTick {
+		cf.tick()
+	}
+
+	if name := aws.ToString(params.StackName); name != "" {
+		s := cf.find(name)
+		if s == nil {
+			return nil, notFound(name)
+		}
+		return &cloudformation.DescribeStacksOutput{Stacks: []cfTypes.Stack{s.describe()}}, nil
+	}
+
+	var stacks []cfTypes.Stack
+	for _, s := range cf.stacks {
+		if s.StackStatus != cfTypes.StackStatusDeleteComplete {
+			stacks = append(stacks, s.describe())
+		}
+	}
+	start, end, next, err := cf.page(params.NextToken, len(stacks))
+	if err != nil {
+		return nil, err
+	}
+	return &cloudformation.DescribeStacksOutput{Stacks: stacks[start:end], NextToken: next}, nil
+}
+
+// DescribeStackEvents implements the DescribeStackEvents operation. Events are returned newest first.
+func (cf *CloudFormation) DescribeStackEvents(_ context.Context, params *cloudformation.DescribeStackEventsInput, _ ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	defer cf.begin(params.StackName)()
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.call("DescribeStackEvents"); err != nil {
+		return nil, err
+	}
+
+	s := cf.find(aws.ToString(params.StackName))
+	if s == nil {
+		return nil, notFound(aws.ToString(params.StackName))
+	}
+
+	events := make([]cfTypes.StackEvent, len(s.events))
+	for i := range s.events {
+		events[i] = s.events[len(s.events)-1-i]
+	}
+	start, end, next, err := cf.page(params.NextToken, len(events))
+	if err != nil {
+		return nil, err
+	}
+	return &cloudformation.DescribeStackEventsOutput{StackEvents: events[start:end], NextToken: next}, nil
+}
+
+// ListStackResources implements the ListStackResources operation.
+func (cf *CloudFormation) ListStackResources(_ context.Context, params *cloudformation.ListStackResourcesInput, _ ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error) {
+	defer cf.begin(params.StackName)()
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.call("ListStackResources"); err != nil {
+		return nil, err
+	}
+
+	s := cf.find(aws.ToString(params.StackName))
+	if s == nil {
+		return nil, notFound(aws.ToString(params.StackName))
+	}
+
+	resources := append([]cfTypes.StackResourceSummary(nil), s.resources...)
+	start, end, next, err := cf.page(params.NextToken, len(resources))
+	if err != nil {
+		return nil, err
+	}
+	return &cloudformation.ListStackResourcesOutput{StackResourceSummaries: resources[start:end], NextToken: next}, nil
+}
+
+// begin tracks a call for the given stack name or ID until the returned func is called, after waiting
+// for the latency.
+func (cf *CloudFormation) begin(nameOrID *string) func() {
+	name := aws.ToString(nameOrID)
+	if strings.HasPrefix(name, "arn:") {
+		// arn:aws:cloudformation:region:account:stack/name/id
+		if parts := strings.Split(name, "/"); len(parts) == 3 {
+			name = parts[1]
+		}
+	}
+
+	cf.mu.Lock()
+	if name != "" {
+		if cf.inFlight[name] > 0 {
+			cf.overlaps[name]++
+		}
+		cf.inFlight[name]++
+	}
+	latency := cf.latency
+	cf.mu.Unlock()
+
+	time.Sleep(latency)
+	return func() {
+		if name == "" {
+			return
+		}
+		cf.mu.Lock()
+		defer cf.mu.Unlock()
+		cf.inFlight[name]--
+	}
+}
+
+// call counts a call of the given operation and fails it if requests are throttled.
+func (cf *CloudFormation) call(operation string) error {
+	cf.calls[operation]++
+	if cf.throttle > 0 {
+		cf.throttle--
+		return &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded", Fault: smithy.FaultClient}
+	}
+	return nil
+}
+
+// find returns the stack with the given ID, or the stack with the given name which isn't deleted.
+func (cf *CloudFormation) find(name string) *stack {
+	for _, s := range cf.stacks {
+		if aws.ToString(s.StackId) == name {
+			return s
+		}
+	}
+	for _, s := range cf.stacks {
+		if aws.ToString(s.StackName) == name && s.StackStatus != cfTypes.StackStatusDeleteComplete {
+			return s
+		}
+	}
+	return nil
+}
+
+// page returns the bounds of the page of a list of the given length and the token of the next page.
+func (cf *CloudFormation) page(token *string, length int) (int, int, *string, error) {
+	start := 0
+	if token != nil {
+		var err error
+		if start, err = strconv.Atoi(*token); err != nil || start > length {
+			return 0, 0, nil, validationError("Invalid NextToken")
+		}
+	}
+
+	size := cf.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	end := start + size
+	if end >= length {
+		return start, length, nil, nil
+	}
+	return start, end, aws.String(strconv.Itoa(end)), nil
+}
+
+// validate parses a template and checks the parameters and capabilities against it.
+func (cf *CloudFormation) validate(body string, parameters []cfTypes.Parameter, capabilities []cfTypes.Capability) (map[string]interface{}, error) {
+	template, err := cloudformationv1alpha1.ParseTemplate(body)
+	if err != nil {
+		return nil, validationError(fmt.Sprintf("Template format error: %v", err))
+	}
+	resources, ok := template["Resources"].(map[string]interface{})
+	if !ok || len(resources) == 0 {
+		return nil, validationError("Template format error: At least one Resources member must be defined.")
+	}
+
+	declared, _ := template["Parameters"].(map[string]interface{})
+	given := parameterMap(parameters)
+	var unknown, missing []string
+	for key := range given {
+		if _, ok := declared[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	for key, value := range declared {
+		if _, ok := given[key]; ok {
+			continue
+		}
+		if definition, ok := value.(map[string]interface{}); !ok || definition["Default"] == nil {
+			missing = append(missing, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, validationError(fmt.Sprintf("Parameters: [%s] do not exist in the template", strings.Join(unknown, ", ")))
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, validationError(fmt.Sprintf("Parameters: [%s] must have values", strings.Join(missing, ", ")))
+	}
+
+	for _, value := range resources {
+		resource, _ := value.(map[string]interface{})
+		if resourceType, _ := resource["Type"].(string); strings.HasPrefix(resourceType, "AWS::IAM::") &&
+			!hasCapability(capabilities, cfTypes.CapabilityCapabilityIam) && !hasCapability(capabilities, cfTypes.CapabilityCapabilityNamedIam) {
+			return nil, &cfTypes.InsufficientCapabilitiesException{Message: aws.String("Requires capabilities : [CAPABILITY_IAM]")}
+		}
+	}
+
+	return template, nil
+}
+
+// tick moves all stacks in progress on by one step.
+func (cf *CloudFormation) tick() {
+	for _, s := range cf.stacks {
+		switch s.StackStatus {
+		case cfTypes.StackStatusCreateInProgress:
+			if s.TimeoutInMinutes != nil && cf.Now().After(s.CreationTime.Add(time.Duration(*s.TimeoutInMinutes)*time.Minute)) {
+				cf.transition(s, cfTypes.StackStatusRollbackInProgress,
+					"Stack creation time exceeded the specified timeout. Rollback requested by user.")
+			} else if reason, failed := cf.provision(s, cfTypes.ResourceStatusCreateComplete, cfTypes.ResourceStatusCreateFailed); failed {
+				cf.transition(s, cfTypes.StackStatusRollbackInProgress,
+					fmt.Sprintf("The following resource(s) failed to create: [%s]. Rollback requested by user.", reason))
+			} else {
+				cf.transition(s, cfTypes.StackStatusCreateComplete, "")
+			}
+		case cfTypes.StackStatusRollbackInProgress:
+			cf.deprovision(s)
+			cf.transition(s, cfTypes.StackStatusRollbackComplete, "")
+		case cfTypes.StackStatusUpdateInProgress:
+			if reason, failed := cf.provision(s, cfTypes.ResourceStatusUpdateComplete, cfTypes.ResourceStatusUpdateFailed); failed {
+				cf.transition(s, cfTypes.StackStatusUpdateRollbackInProgress,
+					fmt.Sprintf("The following resource(s) failed to update: [%s].", reason))
+			} else {
+				s.previous = nil
+				cf.transition(s, cfTypes.StackStatusUpdateComplete, "")
+			}
+		case cfTypes.StackStatusUpdateRollbackInProgress:
+			if failure, ok := failureResource(s.template); ok && failure["FailRollback"] == true {
+				cf.transition(s, cfTypes.StackStatusUpdateRollbackFailed, "The following resource(s) failed to update: [Failure].")
+				continue
+			}
+			s.body, s.template = s.previous.body, s.previous.template
+			s.Capabilities, s.Parameters, s.Tags = s.previous.Capabilities, s.previous.Parameters, s.previous.Tags
+			cf.provision(s, cfTypes.ResourceStatusUpdateComplete, cfTypes.ResourceStatusUpdateFailed)
+			s.previous = nil
+			cf.transition(s, cfTypes.StackStatusUpdateRollbackComplete, "")
+		case cfTypes.StackStatusDeleteInProgress:
+			cf.deprovision(s)
+			cf.transition(s, cfTypes.StackStatusDeleteComplete, "")
+		}
+	}
+}
+
+// provision creates or updates the resources and outputs of the stack's template, failing on the first
+// Fake::Failure resource.
+func (cf *CloudFormation) provision(s *stack, complete, failed cfTypes.ResourceStatus) (string, bool) {
+	resources, _ := s.template["Resources"].(map[string]interface{})
+	var logicalIDs []string
+	for logicalID := range resources {
+		logicalIDs = append(logicalIDs, logicalID)
+	}
+	sort.Strings(logicalIDs)
+
+	now := cf.Now()
+	physicalIDs := map[string]string{}
+	var summaries []cfTypes.StackResourceSummary
+	for _, logicalID := range logicalIDs {
+		resource, _ := resources[logicalID].(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		physicalID := fmt.Sprintf("%s-%s", aws.ToString(s.StackName), logicalID)
+
+		if resourceType == FailureType {
+			reason := "Resource creation failed"
+			if properties, ok := resource["Properties"].(map[string]interface{}); ok {
+				if r, ok := properties["Reason"].(string); ok {
+					reason = r
+				}
+			}
+			cf.event(s, logicalID, physicalID, resourceType, failed, reason)
+			return logicalID, true
+		}
+
+		physicalIDs[logicalID] = physicalID
+		cf.event(s, logicalID, physicalID, resourceType, complete, "")
+		summaries = append(summaries, cfTypes.StackResourceSummary{
+			LogicalResourceId:    aws.String(logicalID),
+			PhysicalResourceId:   aws.String(physicalID),
+			ResourceType:         aws.String(resourceType),
+			ResourceStatus:       complete,
+			LastUpdatedTimestamp: aws.Time(now),
+		})
+	}
+
+	s.resources = summaries
+	s.Outputs = cf.outputs(s, physicalIDs)
+	return "", false
+}
+
+// deprovision deletes all resources of the stack.
+func (cf *CloudFormation) deprovision(s *stack) {
+	for _, resource := range s.resources {
+		cf.event(s, aws.ToString(resource.LogicalResourceId), aws.ToString(resource.PhysicalResourceId),
+			aws.ToString(resource.ResourceType), cfTypes.ResourceStatusDeleteComplete, "")
+	}
+	s.resources = nil
+	s.Outputs = nil
+}
+
+// outputs evaluates the outputs of the stack's template. Ref and Fn::GetAtt are resolved, other
+// intrinsic functions are returned as JSON.
+func (cf *CloudFormation) outputs(s *stack, physicalIDs map[string]string) []cfTypes.Output {
+	declared, _ := s.template["Outputs"].(map[string]interface{})
+	var keys []string
+	for key := range declared {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var outputs []cfTypes.Output
+	for _, key := range keys {
+		output, _ := declared[key].(map[string]interface{})
+		outputs = append(outputs, cfTypes.Output{
+			OutputKey:   aws.String(key),
+			OutputValue: aws.String(evaluate(output["Value"], parameterMap(s.Parameters), physicalIDs)),
+		})
+	}
+	return outputs
+}
+
+// transition moves the stack into the given status and records an event for it.
+func (cf *CloudFormation) transition(s *stack, status cfTypes.StackStatus, reason string) {
+	s.StackStatus = status
+	s.StackStatusReason = nil
+	if reason != "" {
+		s.StackStatusReason = aws.String(reason)
+	}
+	cf.event(s, aws.ToString(s.StackName), aws.ToString(s.StackId), "AWS::CloudFormation::Stack", cfTypes.ResourceStatus(status), reason)
+}
+
+// event records an event of the stack.
+func (cf *CloudFormation) event(s *stack, logicalID, physicalID, resourceType string, status cfTypes.ResourceStatus, reason string) {
+	cf.sequence++
+	event := cfTypes.StackEvent{
+		EventId:            aws.String(strconv.Itoa(cf.sequence)),
+		StackId:            s.StackId,
+		StackName:          s.StackName,
+		LogicalResourceId:  aws.String(logicalID),
+		PhysicalResourceId: aws.String(physicalID),
+		ResourceType:       aws.String(resourceType),
+		ResourceStatus:     status,
+		Timestamp:          aws.Time(cf.Now()),
+	}
+	if reason != "" {
+		event.ResourceStatusReason = aws.String(reason)
+	}
+	s.events = append(s.events, event)
+}
+
+// describe returns a copy of the stack as returned by DescribeStacks.
+func (s *stack) describe() cfTypes.Stack {
+	described := s.Stack
+	described.Capabilities = append([]cfTypes.Capability(nil), s.Capabilities...)
+	described.Parameters = append([]cfTypes.Parameter(nil), s.Parameters...)
+	described.Tags = append([]cfTypes.Tag(nil), s.Tags...)
+	described.Outputs = append([]cfTypes.Output(nil), s.Outputs...)
+	return described
+}
+
+// evaluate returns the value of an output.
+func evaluate(value interface{}, parameters, physicalIDs map[string]string) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if ref, ok := v["Ref"].(string); ok {
+			if parameter, ok := parameters[ref]; ok {
+				return parameter
+			}
+			return physicalIDs[ref]
+		}
+		if getAtt, ok := v["Fn::GetAtt"].([]interface{}); ok && len(getAtt) == 2 {
+			return fmt.Sprintf("%s.%v", physicalIDs[fmt.Sprint(getAtt[0])], getAtt[1])
+		}
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// failureResource returns the properties of the Fake::Failure resource of a template, if any.
+func failureResource(template map[string]interface{}) (map[string]interface{}, bool) {
+	resources, _ := template["Resources"].(map[string]interface{})
+	for _, value := range resources {
+		resource, _ := value.(map[string]interface{})
+		if resource["Type"] == FailureType {
+			properties, _ := resource["Properties"].(map[string]interface{})
+			return properties, true
+		}
+	}
+	return nil, false
+}
+
+// withoutFailure returns a copy of the template without its Fake::Failure resource.
+func withoutFailure(template map[string]interface{}) map[string]interface{} {
+	resources, _ := template["Resources"].(map[string]interface{})
+	copied := map[string]interface{}{}
+	for key, value := range template {
+		copied[key] = value
+	}
+	copiedResources := map[string]interface{}{}
+	for logicalID, value := range resources {
+		if resource, _ := value.(map[string]interface{}); resource["Type"] != FailureType {
+			copiedResources[logicalID] = value
+		}
+	}
+	copied["Resources"] = copiedResources
+	return copied
+}
+
+func parameterMap(parameters []cfTypes.Parameter) map[string]string {
+	m := map[string]string{}
+	for _, p := range parameters {
+		m[aws.ToString(p.ParameterKey)] = aws.ToString(p.ParameterValue)
+	}
+	return m
+}
+
+func tagMap(tags []cfTypes.Tag) map[string]string {
+	m := map[string]string{}
+	for _, t := range tags {
+		m[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return m
+}
+
+func hasCapability(capabilities []cfTypes.Capability, capability cfTypes.Capability) bool {
+	for _, c := range capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func validationError(message string) error {
+	return &smithy.GenericAPIError{Code: "ValidationError", Message: message, Fault: smithy.FaultClient}
+}
+
+func notFound(name string) error {
+	return validationError(fmt.Sprintf("Stack with id %s does not exist", name))
+}
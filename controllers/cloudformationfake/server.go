@@ -0,0 +1,449 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cloudformationfake
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	smithytime "github.com/aws/smithy-go/time"
+)
+
+const (
+	cloudFormationNamespace = "http://cloudformation.amazonaws.com/doc/2010-05-15/"
+	stsNamespace            = "https://sts.amazonaws.com/doc/2011-06-15/"
+)
+
+// Server serves the CloudFormation query protocol for the operations the operator uses, backed by a fake
+// CloudFormation, so that the operator can be run against it with --aws-endpoint-url. It also answers STS
+// GetCallerIdentity requests with the fake's account ID.
+type Server struct {
+	CloudFormation *CloudFormation
+
+	requestID int
+}
+
+// NewServer creates a Server backed by the given fake CloudFormation.
+func NewServer(cf *CloudFormation) *Server {
+	return &Server{CloudFormation: cf}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.writeError(w, &smithy.GenericAPIError{Code: "MalformedQueryString", Message: err.Error(), Fault: smithy.FaultClient})
+		return
+	}
+	form := r.PostForm
+	ctx := r.Context()
+
+	var result interface{}
+	var err error
+	switch action := form.Get("Action"); action {
+	case "CreateStack":
+		var output *cloudformation.CreateStackOutput
+		output, err = s.CloudFormation.CreateStack(ctx, &cloudformation.CreateStackInput{
+			StackName:        optional(form, "StackName"),
+			TemplateBody:     optional(form, "TemplateBody"),
+			Parameters:       parameters(form),
+			Tags:             tags(form),
+			Capabilities:     capabilities(form),
+			TimeoutInMinutes: optionalInt32(form, "TimeoutInMinutes"),
+		})
+		if err == nil {
+			result = stackIDResult{StackID: aws.ToString(output.StackId)}
+		}
+	case "UpdateStack":
+		var output *cloudformation.UpdateStackOutput
+		output, err = s.CloudFormation.UpdateStack(ctx, &cloudformation.UpdateStackInput{
+			StackName:    optional(form, "StackName"),
+			TemplateBody: optional(form, "TemplateBody"),
+			Parameters:   parameters(form),
+			Tags:         tags(form),
+			Capabilities: capabilities(form),
+		})
+		if err == nil {
+			result = stackIDResult{StackID: aws.ToString(output.StackId)}
+		}
+	case "DeleteStack":
+		_, err = s.CloudFormation.DeleteStack(ctx, &cloudformation.DeleteStackInput{StackName: optional(form, "StackName")})
+	case "ContinueUpdateRollback":
+		_, err = s.CloudFormation.ContinueUpdateRollback(ctx, &cloudformation.ContinueUpdateRollbackInput{StackName: optional(form, "StackName")})
+	case "CancelUpdateStack":
+		_, err = s.CloudFormation.CancelUpdateStack(ctx, &cloudformation.CancelUpdateStackInput{StackName: optional(form, "StackName")})
+	case "DescribeStacks":
+		var output *cloudformation.DescribeStacksOutput
+		output, err = s.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+			StackName: optional(form, "StackName"),
+			NextToken: optional(form, "NextToken"),
+		})
+		if err == nil {
+			result = describeStacksResult(output)
+		}
+	case "DescribeStackEvents":
+		var output *cloudformation.DescribeStackEventsOutput
+		output, err = s.CloudFormation.DescribeStackEvents(ctx, &cloudformation.DescribeStackEventsInput{
+			StackName: optional(form, "StackName"),
+			NextToken: optional(form, "NextToken"),
+		})
+		if err == nil {
+			result = describeStackEventsResult(output)
+		}
+	case "ListStackResources":
+		var output *cloudformation.ListStackResourcesOutput
+		output, err = s.CloudFormation.ListStackResources(ctx, &cloudformation.ListStackResourcesInput{
+			StackName: optional(form, "StackName"),
+			NextToken: optional(form, "NextToken"),
+		})
+		if err == nil {
+			result = listStackResourcesResult(output)
+		}
+	case "GetCallerIdentity":
+		s.writeResponse(w, action, stsNamespace, callerIdentityResult{
+			Arn:     fmt.Sprintf("arn:aws:iam::%s:user/fake", s.CloudFormation.AccountID),
+			UserID:  "FAKE",
+			Account: s.CloudFormation.AccountID,
+		})
+		return
+	default:
+		err = &smithy.GenericAPIError{Code: "InvalidAction", Message: fmt.Sprintf("Could not find operation %s", action), Fault: smithy.FaultClient}
+	}
+
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeResponse(w, form.Get("Action"), cloudFormationNamespace, result)
+}
+
+// writeResponse writes the result of an action wrapped in its response element.
+func (s *Server) writeResponse(w http.ResponseWriter, action, namespace string, result interface{}) {
+	type responseMetadata struct {
+		RequestID string `xml:"RequestId"`
+	}
+
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	response := xml.StartElement{
+		Name: xml.Name{Local: action + "Response"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: namespace}},
+	}
+	resultElement := xml.StartElement{Name: xml.Name{Local: action + "Result"}}
+	if result == nil {
+		result = struct{}{}
+	}
+	err := enc.EncodeToken(response)
+	if err == nil {
+		err = enc.EncodeElement(result, resultElement)
+	}
+	if err == nil {
+		err = enc.EncodeElement(responseMetadata{RequestID: s.nextRequestID()}, xml.StartElement{Name: xml.Name{Local: "ResponseMetadata"}})
+	}
+	if err == nil {
+		err = enc.EncodeToken(response.End())
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(buf.Bytes())
+}
+
+// writeError writes an error in the format of the query protocol.
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	type errorDetail struct {
+		Type    string
+		Code    string
+		Message string
+	}
+	type errorResponse struct {
+		XMLName   xml.Name `xml:"ErrorResponse"`
+		Namespace string   `xml:"xmlns,attr"`
+		Error     errorDetail
+		RequestID string `xml:"RequestId"`
+	}
+
+	detail := errorDetail{Type: "Receiver", Code: "InternalFailure", Message: err.Error()}
+	status := http.StatusInternalServerError
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		detail.Code = apiErr.ErrorCode()
+		detail.Message = apiErr.ErrorMessage()
+		if apiErr.ErrorFault() != smithy.FaultServer {
+			detail.Type = "Sender"
+			status = http.StatusBadRequest
+		}
+	}
+
+	encoded, err := xml.Marshal(errorResponse{Namespace: cloudFormationNamespace, Error: detail, RequestID: s.nextRequestID()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(encoded)
+}
+
+func (s *Server) nextRequestID() string {
+	s.CloudFormation.mu.Lock()
+	defer s.CloudFormation.mu.Unlock()
+
+	s.requestID++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.requestID)
+}
+
+type stackIDResult struct {
+	StackID string `xml:"StackId"`
+}
+
+type callerIdentityResult struct {
+	Arn     string
+	UserID  string `xml:"UserId"`
+	Account string
+}
+
+type xmlParameter struct {
+	ParameterKey   string
+	ParameterValue string
+}
+
+type xmlTag struct {
+	Key   string
+	Value string
+}
+
+type xmlOutput struct {
+	OutputKey   string
+	OutputValue string
+}
+
+type xmlStack struct {
+	StackID           string         `xml:"StackId"`
+	StackName         string         `xml:"StackName"`
+	CreationTime      string         `xml:"CreationTime"`
+	LastUpdatedTime   string         `xml:"LastUpdatedTime,omitempty"`
+	DeletionTime      string         `xml:"DeletionTime,omitempty"`
+	StackStatus       string         `xml:"StackStatus"`
+	StackStatusReason string         `xml:"StackStatusReason,omitempty"`
+	TimeoutInMinutes  int32          `xml:"TimeoutInMinutes,omitempty"`
+	Capabilities      []string       `xml:"Capabilities>member"`
+	Parameters        []xmlParameter `xml:"Parameters>member"`
+	Outputs           []xmlOutput    `xml:"Outputs>member"`
+	Tags              []xmlTag       `xml:"Tags>member"`
+}
+
+type xmlStackEvent struct {
+	EventID              string `xml:"EventId"`
+	StackID              string `xml:"StackId"`
+	StackName            string `xml:"StackName"`
+	LogicalResourceID    string `xml:"LogicalResourceId"`
+	PhysicalResourceID   string `xml:"PhysicalResourceId"`
+	ResourceType         string `xml:"ResourceType"`
+	Timestamp            string `xml:"Timestamp"`
+	ResourceStatus       string `xml:"ResourceStatus"`
+	ResourceStatusReason string `xml:"ResourceStatusReason,omitempty"`
+}
+
+type xmlStackResourceSummary struct {
+	LogicalResourceID    string `xml:"LogicalResourceId"`
+	PhysicalResourceID   string `xml:"PhysicalResourceId"`
+	ResourceType         string `xml:"ResourceType"`
+	LastUpdatedTimestamp string `xml:"LastUpdatedTimestamp"`
+	ResourceStatus       string `xml:"ResourceStatus"`
+	ResourceStatusReason string `xml:"ResourceStatusReason,omitempty"`
+}
+
+func describeStacksResult(output *cloudformation.DescribeStacksOutput) interface{} {
+	type result struct {
+		Stacks    []xmlStack `xml:"Stacks>member"`
+		NextToken string     `xml:"NextToken,omitempty"`
+	}
+
+	r := result{NextToken: aws.ToString(output.NextToken)}
+	for _, stack := range output.Stacks {
+		s := xmlStack{
+			StackID:           aws.ToString(stack.StackId),
+			StackName:         aws.ToString(stack.StackName),
+			CreationTime:      formatTime(stack.CreationTime),
+			LastUpdatedTime:   formatTime(stack.LastUpdatedTime),
+			DeletionTime:      formatTime(stack.DeletionTime),
+			StackStatus:       string(stack.StackStatus),
+			StackStatusReason: aws.ToString(stack.StackStatusReason),
+			TimeoutInMinutes:  aws.ToInt32(stack.TimeoutInMinutes),
+		}
+		for _, c := range stack.Capabilities {
+			s.Capabilities = append(s.Capabilities, string(c))
+		}
+		for _, p := range stack.Parameters {
+			s.Parameters = append(s.Parameters, xmlParameter{aws.ToString(p.ParameterKey), aws.ToString(p.ParameterValue)})
+		}
+		for _, o := range stack.Outputs {
+			s.Outputs = append(s.Outputs, xmlOutput{aws.ToString(o.OutputKey), aws.ToString(o.OutputValue)})
+		}
+		for _, t := range stack.Tags {
+			s.Tags = append(s.Tags, xmlTag{aws.ToString(t.Key), aws.ToString(t.Value)})
+		}
+		r.Stacks = append(r.Stacks, s)
+	}
+	return r
+}
+
+func describeStackEventsResult(output *cloudformation.DescribeStackEventsOutput) interface{} {
+	type result struct {
+		StackEvents []xmlStackEvent `xml:"StackEvents>member"`
+		NextToken   string          `xml:"NextToken,omitempty"`
+	}
+
+	r := result{NextToken: aws.ToString(output.NextToken)}
+	for _, event := range output.StackEvents {
+		r.StackEvents = append(r.StackEvents, xmlStackEvent{
+			EventID:              aws.ToString(event.EventId),
+			StackID:              aws.ToString(event.StackId),
+			StackName:            aws.ToString(event.StackName),
+			LogicalResourceID:    aws.ToString(event.LogicalResourceId),
+			PhysicalResourceID:   aws.ToString(event.PhysicalResourceId),
+			ResourceType:         aws.ToString(event.ResourceType),
+			Timestamp:            formatTime(event.Timestamp),
+			ResourceStatus:       string(event.ResourceStatus),
+			ResourceStatusReason: aws.ToString(event.ResourceStatusReason),
+		})
+	}
+	return r
+}
+
+func listStackResourcesResult(output *cloudformation.ListStackResourcesOutput) interface{} {
+	type result struct {
+		StackResourceSummaries []xmlStackResourceSummary `xml:"StackResourceSummaries>member"`
+		NextToken              string                    `xml:"NextToken,omitempty"`
+	}
+
+	r := result{NextToken: aws.ToString(output.NextToken)}
+	for _, resource := range output.StackResourceSummaries {
+		r.StackResourceSummaries = append(r.StackResourceSummaries, xmlStackResourceSummary{
+			LogicalResourceID:    aws.ToString(resource.LogicalResourceId),
+			PhysicalResourceID:   aws.ToString(resource.PhysicalResourceId),
+			ResourceType:         aws.ToString(resource.ResourceType),
+			LastUpdatedTimestamp: formatTime(resource.LastUpdatedTimestamp),
+			ResourceStatus:       string(resource.ResourceStatus),
+			ResourceStatusReason: aws.ToString(resource.ResourceStatusReason),
+		})
+	}
+	return r
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return smithytime.FormatDateTime(t.UTC())
+}
+
+// optional returns the value of a form field, nil if it's missing.
+func optional(form url.Values, key string) *string {
+	if _, ok := form[key]; !ok {
+		return nil
+	}
+	return aws.String(form.Get(key))
+}
+
+// optionalInt32 returns the value of a numeric form field, nil if it's missing or not a number.
+func optionalInt32(form url.Values, key string) *int32 {
+	value, err := strconv.ParseInt(form.Get(key), 10, 32)
+	if err != nil {
+		return nil
+	}
+	return aws.Int32(int32(value))
+}
+
+// members returns the values of a list, which the query protocol flattens into fields named
+// <name>.member.<n>[.<field>].
+func members(form url.Values, name, field string) []string {
+	var values []string
+	for i := 1; ; i++ {
+		key := name + ".member." + strconv.Itoa(i)
+		if field != "" {
+			key += "." + field
+		}
+		if _, ok := form[key]; !ok {
+			return values
+		}
+		values = append(values, form.Get(key))
+	}
+}
+
+func parameters(form url.Values) []cfTypes.Parameter {
+	keys := members(form, "Parameters", "ParameterKey")
+	values := members(form, "Parameters", "ParameterValue")
+	var params []cfTypes.Parameter
+	for i := range keys {
+		p := cfTypes.Parameter{ParameterKey: aws.String(keys[i])}
+		if i < len(values) {
+			p.ParameterValue = aws.String(values[i])
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+func tags(form url.Values) []cfTypes.Tag {
+	keys := members(form, "Tags", "Key")
+	values := members(form, "Tags", "Value")
+	var t []cfTypes.Tag
+	for i := range keys {
+		tag := cfTypes.Tag{Key: aws.String(keys[i])}
+		if i < len(values) {
+			tag.Value = aws.String(values[i])
+		}
+		t = append(t, tag)
+	}
+	return t
+}
+
+func capabilities(form url.Values) []cfTypes.Capability {
+	var c []cfTypes.Capability
+	for _, value := range members(form, "Capabilities", "") {
+		c = append(c, cfTypes.Capability(value))
+	}
+	return c
+}
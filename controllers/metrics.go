@@ -0,0 +1,128 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	statusUpdateConflicts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudformation_operator_status_update_conflicts_total",
+			Help: "Number of Stack status updates that conflicted with a concurrent change, by writer.",
+		},
+		[]string{"writer"},
+	)
+	stackNextPoll = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudformation_operator_stack_next_poll_timestamp_seconds",
+			Help: "Unix time at which the CloudFormation stack of a followed Stack is polled next.",
+		},
+		[]string{"namespace", "name"},
+	)
+	awsThrottledRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudformation_operator_aws_throttled_requests_total",
+			Help: "Number of AWS API requests that were throttled, by operation.",
+		},
+		[]string{"operation"},
+	)
+	awsRetriedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudformation_operator_aws_retried_requests_total",
+			Help: "Number of times AWS API requests were retried, by operation.",
+		},
+		[]string{"operation"},
+	)
+	awsRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudformation_operator_aws_requests_total",
+			Help: "Number of AWS API requests, by operation and error code.",
+		},
+		[]string{"operation", "code"},
+	)
+	awsRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cloudformation_operator_aws_request_duration_seconds",
+			Help:    "Latency of AWS API requests including retries, by operation.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+	stackOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cloudformation_operator_stack_operation_duration_seconds",
+			Help:    "Time from submitting a stack operation until it finished, by operation and result.",
+			Buckets: prometheus.ExponentialBuckets(15, 2, 10),
+		},
+		[]string{"operation", "result"},
+	)
+	stackFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudformation_operator_stack_failures_total",
+			Help: "Number of stack operations that ended in a failed state, by namespace and operation.",
+		},
+		[]string{"namespace", "operation"},
+	)
+	stackRollbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudformation_operator_stack_rollbacks_total",
+			Help: "Number of stack operations that were rolled back, by namespace and operation.",
+		},
+		[]string{"namespace", "operation"},
+	)
+	followedStacks = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cloudformation_operator_followed_stacks",
+			Help: "Number of stacks the follower is polling.",
+		},
+	)
+	awsRateLimit = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloudformation_operator_aws_rate_limit",
+			Help: "Current number of AWS API requests per second allowed for an account and region.",
+		},
+		[]string{"account", "region"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		statusUpdateConflicts,
+		stackNextPoll,
+		awsThrottledRequests,
+		awsRetriedRequests,
+		awsRequests,
+		awsRequestDuration,
+		stackOperationDuration,
+		stackFailures,
+		stackRollbacks,
+		followedStacks,
+		awsRateLimit,
+	)
+}
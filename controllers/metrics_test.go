@@ -0,0 +1,55 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var _ = Describe("Metrics", func() {
+	collectors := map[string]prometheus.Collector{
+		"status update conflicts":  statusUpdateConflicts,
+		"stack next poll":          stackNextPoll,
+		"aws throttled requests":   awsThrottledRequests,
+		"aws retried requests":     awsRetriedRequests,
+		"aws requests":             awsRequests,
+		"aws request duration":     awsRequestDuration,
+		"stack operation duration": stackOperationDuration,
+		"stack failures":           stackFailures,
+		"stack rollbacks":          stackRollbacks,
+		"followed stacks":          followedStacks,
+		"aws rate limit":           awsRateLimit,
+	}
+
+	It("registers all metrics with the controller-runtime registry", func() {
+		for name, collector := range collectors {
+			err := metrics.Registry.Register(collector)
+			Expect(err).To(BeAssignableToTypeOf(prometheus.AlreadyRegisteredError{}), name)
+		}
+	})
+})
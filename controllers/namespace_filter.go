@@ -0,0 +1,108 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// NamespaceFilter selects the namespaces whose Stacks the operator manages. Stacks in other namespaces are
+// neither reconciled nor followed, a nil NamespaceFilter selects all namespaces. Only a Selector needs access
+// to Namespaces, which isn't part of the manager's role as most installations don't use one.
+type NamespaceFilter struct {
+	// Namespaces to manage, all if empty.
+	Namespaces []string
+	// Selector of the labels of the namespaces to manage, all if nil.
+	Selector labels.Selector
+	// Cache of Namespaces, required with a Selector.
+	Cache cache.Cache
+}
+
+// Matches returns whether the Stacks of the given namespace are managed.
+func (f *NamespaceFilter) Matches(ctx context.Context, namespace string) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+	if !f.listed(namespace) {
+		return false, nil
+	}
+	if f.Selector == nil {
+		return true, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := f.Cache.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return f.Selector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// listed returns whether the namespace is among the Namespaces, if any.
+func (f *NamespaceFilter) listed(namespace string) bool {
+	if len(f.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range f.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitNamespaces returns the namespaces of a comma-separated list, leaving out empty ones.
+func SplitNamespaces(list string) []string {
+	var namespaces []string
+	for _, ns := range strings.Split(list, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// namespaceChanged passes Namespaces which were added or whose labels changed, as that may change whether
+// their Stacks are managed.
+var namespaceChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !labels.Equals(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+	},
+	DeleteFunc: func(event.DeleteEvent) bool {
+		return false
+	},
+}
@@ -0,0 +1,152 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+	"github.com/linki/cloudformation-operator/controllers/cloudformationfake"
+)
+
+var _ = Describe("Namespace filter", func() {
+	ctx := context.Background()
+
+	It("splits comma-separated lists of namespaces", func() {
+		Expect(SplitNamespaces("team-a, team-b,,team-c ")).To(Equal([]string{"team-a", "team-b", "team-c"}))
+		Expect(SplitNamespaces("")).To(BeEmpty())
+	})
+
+	It("only manages Stacks in the listed namespaces", func() {
+		// The suite's operator ignores these namespaces, a second one only watches two of them.
+		for _, name := range []string{"multi-a", "multi-b", "multi-c"} {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{ignoredNamespaceLabel: "true"},
+			}}
+			if err := k8sClient.Create(ctx, ns); !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+
+		namespaces := SplitNamespaces("multi-a, multi-b")
+		mgr, err := ctrl.NewManager(testEnv.Config, ctrl.Options{
+			Scheme:             scheme.Scheme,
+			MetricsBindAddress: "0",
+			NewCache:           cache.MultiNamespacedCacheBuilder(namespaces),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		cf := cloudformationfake.New()
+		cf.AutoTick = true
+		cfHelper := &CloudFormationHelper{CloudFormation: cf}
+		namespaceFilter := &NamespaceFilter{Namespaces: namespaces}
+		follower := NewStackFollower(mgr.GetClient(), ctrl.Log.WithName("workers").WithName("multi"), cfHelper)
+		follower.APIReader = mgr.GetAPIReader()
+		follower.Recorder = mgr.GetEventRecorderFor("cloudformation-operator")
+		follower.PollInterval = 50 * time.Millisecond
+		follower.MaxPollInterval = 200 * time.Millisecond
+		follower.Namespaces = namespaceFilter
+		follower.Stacks = &StackFilter{}
+		stackLocks := NewStackLocks()
+		follower.StackLocks = stackLocks
+		Expect(mgr.Add(follower)).To(Succeed())
+		Expect((&StackReconciler{
+			Client:               mgr.GetClient(),
+			Log:                  ctrl.Log.WithName("controllers").WithName("multi"),
+			Scheme:               mgr.GetScheme(),
+			APIReader:            mgr.GetAPIReader(),
+			Recorder:             mgr.GetEventRecorderFor("cloudformation-operator"),
+			CloudFormation:       cf,
+			StackFollower:        follower,
+			CloudFormationHelper: cfHelper,
+			Namespaces:           namespaceFilter,
+			Stacks:               &StackFilter{},
+			StackLocks:           stackLocks,
+			Settings:             &Settings{},
+		}).SetupWithManager(mgr)).To(Succeed())
+
+		mgrCtx, stop := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(mgr.Start(mgrCtx)).To(Succeed())
+		}()
+		defer func() {
+			stop()
+			<-done
+		}()
+
+		var stacks []*cloudformationv1alpha1.Stack
+		for _, namespace := range []string{"multi-a", "multi-b", "multi-c"} {
+			instance := &cloudformationv1alpha1.Stack{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: namespace},
+				Spec: cloudformationv1alpha1.StackSpec{
+					Template:   bucketTemplate,
+					Parameters: map[string]string{"BucketName": namespace + "-bucket"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			stacks = append(stacks, instance)
+		}
+
+		for _, instance := range stacks[:2] {
+			key := client.ObjectKeyFromObject(instance)
+			Eventually(func() string {
+				stack := &cloudformationv1alpha1.Stack{}
+				Expect(k8sClient.Get(ctx, key, stack)).To(Succeed())
+				return stack.Status.StackStatus
+			}, timeout, interval).Should(Equal("CREATE_COMPLETE"))
+		}
+		Consistently(func() string {
+			stack := &cloudformationv1alpha1.Stack{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(stacks[2]), stack)).To(Succeed())
+			return stack.Status.StackStatus
+		}, 500*time.Millisecond, interval).Should(BeEmpty())
+		Expect(cf.Calls("CreateStack")).To(Equal(2))
+
+		// The operator deletes the stacks of its namespaces and removes their finalizers.
+		for _, instance := range stacks {
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+			key := client.ObjectKeyFromObject(instance)
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, key, &cloudformationv1alpha1.Stack{}))
+			}, timeout, interval).Should(BeTrue())
+		}
+	})
+})
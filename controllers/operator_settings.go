@@ -0,0 +1,247 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+const defaultConfigReloadInterval = 10 * time.Second
+
+// StackSettings are the operator's settings for the Stacks of one namespace.
+type StackSettings struct {
+	Tags         map[string]string
+	Capabilities []cfTypes.Capability
+	DryRun       bool
+	RequiredTags []string
+}
+
+// Settings combine the settings given on the command-line with those of the operator's config file,
+// which may be replaced while the operator runs.
+type Settings struct {
+	// Defaults come from the command-line. Tags of the config file are added to them, its capabilities
+	// and dry run replace them.
+	Defaults StackSettings
+
+	mu     sync.RWMutex
+	config *cloudformationv1alpha1.OperatorConfig
+}
+
+// SetConfig replaces the config file the settings are taken from.
+func (s *Settings) SetConfig(config *cloudformationv1alpha1.OperatorConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// For returns the settings of the Stacks in the given namespace. A nil Settings has no defaults.
+func (s *Settings) For(namespace string) StackSettings {
+	if s == nil {
+		return StackSettings{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings := StackSettings{
+		Tags:         map[string]string{},
+		Capabilities: s.Defaults.Capabilities,
+		DryRun:       s.Defaults.DryRun,
+		RequiredTags: append([]string{}, s.Defaults.RequiredTags...),
+	}
+	for k, v := range s.Defaults.Tags {
+		settings.Tags[k] = v
+	}
+	if s.config == nil {
+		return settings
+	}
+
+	apply := func(tags map[string]string, capabilities []string, dryRun *bool, requiredTags []string) {
+		for k, v := range tags {
+			settings.Tags[k] = v
+		}
+		if capabilities != nil {
+			settings.Capabilities = make([]cfTypes.Capability, len(capabilities))
+			for i := range capabilities {
+				settings.Capabilities[i] = cfTypes.Capability(capabilities[i])
+			}
+		}
+		if dryRun != nil {
+			settings.DryRun = *dryRun
+		}
+		settings.RequiredTags = append(settings.RequiredTags, requiredTags...)
+	}
+
+	defaults := s.config.Defaults
+	apply(defaults.Tags, defaults.Capabilities, defaults.DryRun, s.config.Policy.RequiredTags)
+	if ns, ok := s.config.Namespaces[namespace]; ok {
+		apply(ns.Tags, ns.Capabilities, ns.DryRun, ns.RequiredTags)
+	}
+	return settings
+}
+
+// missingTags returns the required tags which aren't among the given keys, sorted.
+func (s StackSettings) missingTags(keys map[string]bool) []string {
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, key := range s.RequiredTags {
+		if !keys[key] && !seen[key] {
+			missing = append(missing, key)
+		}
+		seen[key] = true
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// policyViolationError is returned when a Stack violates the policy of the operator's config file.
+type policyViolationError struct {
+	message string
+}
+
+func (e *policyViolationError) Error() string {
+	return e.message
+}
+
+func missingTagsError(missing []string) error {
+	return &policyViolationError{message: fmt.Sprintf("missing required tags: %s", strings.Join(missing, ", "))}
+}
+
+// LoadOperatorConfig reads the operator's config file, the scheme must contain the OperatorConfig type.
+func LoadOperatorConfig(path string, scheme *runtime.Scheme) (*cloudformationv1alpha1.OperatorConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeOperatorConfig(content, scheme)
+}
+
+func decodeOperatorConfig(content []byte, scheme *runtime.Scheme) (*cloudformationv1alpha1.OperatorConfig, error) {
+	config := &cloudformationv1alpha1.OperatorConfig{}
+	if err := runtime.DecodeInto(serializer.NewCodecFactory(scheme).UniversalDecoder(), content, config); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	return config, nil
+}
+
+// ConfigWatcher reloads the operator's config file when it changes and has all Stacks reconciled with the
+// new settings. It runs as a manager Runnable and polls the file, which works with mounted ConfigMaps.
+type ConfigWatcher struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Path of the config file.
+	Path string
+	// Interval between reading the config file.
+	Interval time.Duration
+	// Settings receive the config file whenever it changed.
+	Settings *Settings
+	// Changed receives all Stacks after the config file changed, the StackReconciler watches it.
+	Changed chan<- event.GenericEvent
+
+	content []byte
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the config file only matters to the
+// elected leader, which reconciles Stacks.
+func (w *ConfigWatcher) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable.
+func (w *ConfigWatcher) Start(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultConfigReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Pick up changes since the operator started, without reconciling all Stacks again.
+	w.reload(ctx, false)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.reload(ctx, true)
+		}
+	}
+}
+
+// reload reads the config file and replaces the settings if it changed. A config file which can't be read
+// is logged and the previous settings are kept.
+func (w *ConfigWatcher) reload(ctx context.Context, notify bool) {
+	content, err := ioutil.ReadFile(w.Path)
+	if err != nil {
+		w.Log.Error(err, "unable to read config file", "path", w.Path)
+		return
+	}
+	if bytes.Equal(content, w.content) {
+		return
+	}
+
+	config, err := decodeOperatorConfig(content, w.Scheme)
+	if err != nil {
+		w.Log.Error(err, "unable to reload config file, keeping the previous settings", "path", w.Path)
+		return
+	}
+	w.content = content
+	w.Settings.SetConfig(config)
+	w.Log.Info("reloaded config file", "path", w.Path)
+
+	if !notify || w.Changed == nil {
+		return
+	}
+	stacks := &cloudformationv1alpha1.StackList{}
+	if err := w.List(ctx, stacks); err != nil {
+		w.Log.Error(err, "unable to list Stacks to apply the new settings")
+		return
+	}
+	for i := range stacks.Items {
+		select {
+		case w.Changed <- event.GenericEvent{Object: &stacks.Items[i]}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,268 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"golang.org/x/sync/singleflight"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+// StackCache keeps the stacks of the account and region for a short time, so that looking up many stacks
+// takes a few paginated DescribeStacks calls instead of one call per stack. Stacks missing from the cache,
+// e.g. deleted ones, which DescribeStacks only returns when asked for by ID, are described one by one.
+type StackCache struct {
+	CloudFormation CloudFormationAPI
+	TTL            time.Duration
+
+	// refreshes makes concurrent lookups wait for a single refresh.
+	refreshes singleflight.Group
+
+	// mu guards the fields below, it's never held while calling CloudFormation.
+	mu          sync.Mutex
+	refreshedAt time.Time
+	// refreshing is set while the stacks are described, so that invalidated stacks aren't brought back by
+	// a refresh which started before they changed.
+	refreshing  bool
+	invalidated []string
+	// stack name -> stack, only for stacks which aren't deleted
+	byName map[string]cachedStack
+	// stack ID -> stack
+	byID map[string]cachedStack
+	// stack ID -> resources
+	resources map[string]stackResources
+}
+
+// cachedStack is a stack together with when it was described.
+type cachedStack struct {
+	stack       *cfTypes.Stack
+	describedAt time.Time
+}
+
+// stackResources are the resources of a stack as they were when it was last updated. Only the resources
+// of stacks in a terminal state are kept, as they change while an operation is in progress.
+type stackResources struct {
+	lastUpdated time.Time
+	stackStatus cfTypes.StackStatus
+	resources   []cloudformationv1alpha1.StackResource
+}
+
+// NewStackCache creates a StackCache which describes all stacks again once they're older than ttl.
+func NewStackCache(client CloudFormationAPI, ttl time.Duration) *StackCache {
+	return &StackCache{
+		CloudFormation: client,
+		TTL:            ttl,
+		byName:         map[string]cachedStack{},
+		byID:           map[string]cachedStack{},
+		resources:      map[string]stackResources{},
+	}
+}
+
+// GetStack returns the stack with the given name or ID.
+func (c *StackCache) GetStack(ctx context.Context, name string) (*cfTypes.Stack, error) {
+	if c.expired() {
+		_, err, _ := c.refreshes.Do("", func() (interface{}, error) {
+			if !c.expired() {
+				return nil, nil
+			}
+			return nil, c.refresh(ctx)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if stack, ok := c.lookup(name); ok {
+		return stack, nil
+	}
+
+	describedAt := time.Now()
+	resp, err := c.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Stacks) != 1 {
+		return nil, ErrStackNotFound
+	}
+
+	stack := &resp.Stacks[0]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(stack, describedAt)
+	return stack, nil
+}
+
+// Invalidate drops the given stack from the cache. It must be called after changing a stack, so that
+// the change is seen right away.
+func (c *StackCache) Invalidate(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidate(names...)
+	if c.refreshing {
+		c.invalidated = append(c.invalidated, names...)
+	}
+}
+
+func (c *StackCache) invalidate(names ...string) {
+	for _, name := range names {
+		if cached, ok := c.byID[name]; ok {
+			delete(c.byName, aws.ToString(cached.stack.StackName))
+			delete(c.byID, name)
+		}
+		if cached, ok := c.byName[name]; ok {
+			delete(c.byID, aws.ToString(cached.stack.StackId))
+			delete(c.byName, name)
+		}
+	}
+}
+
+// expired returns whether the stacks need to be described again.
+func (c *StackCache) expired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return time.Since(c.refreshedAt) > c.TTL
+}
+
+// lookup returns the cached stack with the given name or ID. The stacks of the last refresh are used until
+// the next one, while stacks described on their own since are only used for TTL after they were described.
+func (c *StackCache) lookup(name string) (*cfTypes.Stack, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.byID[name]
+	if !ok {
+		cached, ok = c.byName[name]
+	}
+	if !ok || (cached.describedAt.After(c.refreshedAt) && time.Since(cached.describedAt) > c.TTL) {
+		return nil, false
+	}
+	return cached.stack, true
+}
+
+// refresh describes all stacks of the account and region.
+func (c *StackCache) refresh(ctx context.Context) error {
+	c.mu.Lock()
+	c.refreshing = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.refreshing = false
+		c.invalidated = nil
+		c.mu.Unlock()
+	}()
+
+	byName := map[string]cachedStack{}
+	byID := map[string]cachedStack{}
+	// The stacks are as old as the start of the refresh, which may take several pages.
+	describedAt := time.Now()
+
+	var next *string
+	for {
+		resp, err := c.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+			NextToken: next,
+		})
+		if err != nil {
+			return err
+		}
+
+		for i := range resp.Stacks {
+			cached := cachedStack{stack: &resp.Stacks[i], describedAt: describedAt}
+			byName[aws.ToString(cached.stack.StackName)] = cached
+			byID[aws.ToString(cached.stack.StackId)] = cached
+		}
+
+		next = resp.NextToken
+		if next == nil {
+			break
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id := range c.resources {
+		if _, ok := byID[id]; !ok {
+			delete(c.resources, id)
+		}
+	}
+
+	c.byName = byName
+	c.byID = byID
+	c.invalidate(c.invalidated...)
+	c.refreshedAt = describedAt
+	return nil
+}
+
+// store adds a stack described on its own, c.mu must be held.
+func (c *StackCache) store(stack *cfTypes.Stack, describedAt time.Time) {
+	cached := cachedStack{stack: stack, describedAt: describedAt}
+	if stack.StackStatus != cfTypes.StackStatusDeleteComplete {
+		c.byName[aws.ToString(stack.StackName)] = cached
+	}
+	c.byID[aws.ToString(stack.StackId)] = cached
+}
+
+// getResources returns the cached resources of the given stack unless it changed since they were listed.
+func (c *StackCache) getResources(stack *cfTypes.Stack) ([]cloudformationv1alpha1.StackResource, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.resources[aws.ToString(stack.StackId)]
+	if !ok || !cached.lastUpdated.Equal(stackLastUpdated(stack)) || cached.stackStatus != stack.StackStatus {
+		return nil, false
+	}
+	return cached.resources, true
+}
+
+func (c *StackCache) storeResources(stack *cfTypes.Stack, resources []cloudformationv1alpha1.StackResource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resources[aws.ToString(stack.StackId)] = stackResources{
+		lastUpdated: stackLastUpdated(stack),
+		stackStatus: stack.StackStatus,
+		resources:   resources,
+	}
+}
+
+// stackLastUpdated returns when the stack was last updated, or created if it was never updated.
+func stackLastUpdated(stack *cfTypes.Stack) time.Time {
+	if stack.LastUpdatedTime != nil {
+		return *stack.LastUpdatedTime
+	}
+	return aws.ToTime(stack.CreationTime)
+}
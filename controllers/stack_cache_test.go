@@ -0,0 +1,186 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/linki/cloudformation-operator/controllers/cloudformationfake"
+)
+
+var _ = Describe("Stack cache", func() {
+	ctx := context.Background()
+
+	var fake *cloudformationfake.CloudFormation
+	var cache *StackCache
+
+	createStack := func(name string) string {
+		resp, err := fake.CreateStack(ctx, &cloudformation.CreateStackInput{
+			StackName:    aws.String(name),
+			TemplateBody: aws.String(bucketTemplate),
+			Parameters:   []cfTypes.Parameter{{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String(name)}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return aws.ToString(resp.StackId)
+	}
+
+	BeforeEach(func() {
+		fake = cloudformationfake.New()
+		cache = NewStackCache(fake, time.Hour)
+		createStack("first")
+		createStack("second")
+	})
+
+	It("describes all stacks once until they expire", func() {
+		first, err := cache.GetStack(ctx, "first")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aws.ToString(first.StackName)).To(Equal("first"))
+		second, err := cache.GetStack(ctx, aws.ToString(first.StackId))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).To(BeIdenticalTo(first))
+		_, err = cache.GetStack(ctx, "second")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.Calls("DescribeStacks")).To(Equal(1))
+
+		cache.TTL = 0
+		_, err = cache.GetStack(ctx, "first")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.Calls("DescribeStacks")).To(Equal(2))
+	})
+
+	It("describes invalidated stacks on their own", func() {
+		_, err := cache.GetStack(ctx, "first")
+		Expect(err).NotTo(HaveOccurred())
+
+		fake.Tick()
+		cache.Invalidate("first")
+		first, err := cache.GetStack(ctx, "first")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first.StackStatus).To(Equal(cfTypes.StackStatusCreateComplete))
+		second, err := cache.GetStack(ctx, "second")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.StackStatus).To(Equal(cfTypes.StackStatusCreateInProgress))
+		Expect(fake.Calls("DescribeStacks")).To(Equal(2))
+	})
+
+	It("describes stacks missing from the cache on their own", func() {
+		_, err := cache.GetStack(ctx, "first")
+		Expect(err).NotTo(HaveOccurred())
+
+		createStack("third")
+		third, err := cache.GetStack(ctx, "third")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aws.ToString(third.StackName)).To(Equal("third"))
+
+		_, err = cache.GetStack(ctx, "missing")
+		Expect(classifyError(err)).To(Equal(ErrorClassNotFound))
+		Expect(fake.Calls("DescribeStacks")).To(Equal(3))
+	})
+
+	It("describes stacks described on their own again once they expire", func() {
+		cache.TTL = 200 * time.Millisecond
+		_, err := cache.GetStack(ctx, "first")
+		Expect(err).NotTo(HaveOccurred())
+
+		createStack("third")
+		for i := 0; i < 2; i++ {
+			third, err := cache.GetStack(ctx, "third")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(third.StackStatus).To(Equal(cfTypes.StackStatusCreateInProgress))
+		}
+		Expect(fake.Calls("DescribeStacks")).To(Equal(2))
+
+		fake.Tick()
+		Eventually(func() cfTypes.StackStatus {
+			third, err := cache.GetStack(ctx, "third")
+			Expect(err).NotTo(HaveOccurred())
+			return third.StackStatus
+		}, timeout, interval).Should(Equal(cfTypes.StackStatusCreateComplete))
+	})
+
+	It("lists the resources of stacks in progress on every lookup", func() {
+		helper := &CloudFormationHelper{CloudFormation: fake, Cache: cache}
+		getResources := func() {
+			stack, err := cache.GetStack(ctx, "first")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = helper.GetStackResources(ctx, stack)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		getResources()
+		getResources()
+		Expect(fake.Calls("ListStackResources")).To(Equal(2))
+
+		fake.Tick()
+		cache.Invalidate("first")
+		getResources()
+		getResources()
+		Expect(fake.Calls("ListStackResources")).To(Equal(3))
+	})
+
+	It("refreshes once for concurrent lookups without blocking other lookups", func() {
+		fake.SetLatency(200 * time.Millisecond)
+
+		var wg sync.WaitGroup
+		for _, name := range []string{"first", "second", "first", "second"} {
+			wg.Add(1)
+			go func(name string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := cache.GetStack(ctx, name)
+				Expect(err).NotTo(HaveOccurred())
+			}(name)
+		}
+		wg.Wait()
+		Expect(fake.Calls("DescribeStacks")).To(Equal(1))
+
+		// Stacks missing from the cache are described at the same time.
+		for _, name := range []string{"third", "fourth"} {
+			createStack(name)
+		}
+		started := time.Now()
+		for _, name := range []string{"third", "fourth"} {
+			wg.Add(1)
+			go func(name string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := cache.GetStack(ctx, name)
+				Expect(err).NotTo(HaveOccurred())
+			}(name)
+		}
+		wg.Wait()
+		Expect(time.Since(started)).To(BeNumerically("<", 400*time.Millisecond))
+		Expect(fake.Calls("DescribeStacks")).To(Equal(3))
+	})
+})
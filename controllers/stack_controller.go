@@ -27,13 +27,27 @@ package controllers
 
 import (
 	"context"
+	coreerrors "errors"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	"strings"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -55,14 +69,30 @@ const (
 // StackReconciler reconciles a Stack object
 type StackReconciler struct {
 	client.Client
-	Log                  logr.Logger
-	Scheme               *runtime.Scheme
-	CloudFormation       *cloudformation.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// APIReader reads the latest version of a Stack after a conflicting status update.
+	APIReader            client.Reader
+	Recorder             record.EventRecorder
+	CloudFormation       CloudFormationAPI
 	StackFollower        *StackFollower
 	CloudFormationHelper *CloudFormationHelper
-	DefaultTags          map[string]string
-	DefaultCapabilities  []cfTypes.Capability
-	DryRun               bool
+	// Namespaces whose Stacks are reconciled, nil for all.
+	Namespaces *NamespaceFilter
+	// Stacks reconciled by this instance, nil for all.
+	Stacks *StackFilter
+	// StackLocks are shared with the StackFollower, so that no two operations on a stack overlap.
+	StackLocks *StackLocks
+	// MaxConcurrentReconciles is the number of Stacks reconciled at once, 1 if 0.
+	MaxConcurrentReconciles int
+	// RecreateDelay is the time to wait before recreating a stack whose creation failed the first time, it
+	// doubles with each attempt. 30 seconds if 0.
+	RecreateDelay time.Duration
+	// Settings are the defaults and policy applied to Stacks, a nil Settings has none.
+	Settings *Settings
+	// SettingsChanged receives all Stacks whenever the Settings changed.
+	SettingsChanged   <-chan event.GenericEvent
+	OperatorVariables OperatorVariables
 }
 
 type StackLoop struct {
@@ -70,24 +100,34 @@ type StackLoop struct {
 	req      ctrl.Request
 	instance *cloudformationv1alpha1.Stack
 	stack    *cfTypes.Stack
+	settings StackSettings
+	// forced is set if the ReconcileRequestAnnotation asks for a reconcile not handled yet.
+	forced bool
 }
 
 // +kubebuilder:rbac:groups=cloudformation.linki.space,resources=stacks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cloudformation.linki.space,resources=stacks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cloudformation.linki.space,resources=stacks/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.0/pkg/reconcile
-func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	_ = r.Log.WithValues("Request.Namespace", req.Namespace, "Request.Name", req.Name)
 
-	loop := &StackLoop{ctx, req, &cloudformationv1alpha1.Stack{}, nil}
+	ctx, span := tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		semconv.K8SNamespaceNameKey.String(req.Namespace),
+		stackNameKey.String(req.Name),
+	))
+	defer func() { endSpan(span, err) }()
+
+	loop := &StackLoop{ctx: ctx, req: req, instance: &cloudformationv1alpha1.Stack{}, settings: r.Settings.For(req.Namespace)}
 
 	// Fetch the Stack instance
-	err := r.Client.Get(loop.ctx, loop.req.NamespacedName, loop.instance)
+	err = r.Client.Get(loop.ctx, loop.req.NamespacedName, loop.instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
@@ -100,6 +140,37 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		r.Log.Error(err, "Failed to get Stack")
 		return ctrl.Result{}, err
 	}
+	span.SetAttributes(stackAttributes(loop.instance)...)
+
+	// A Stack which is being deleted is finalized even if its namespace was deselected in the meantime.
+	managed, err := r.Namespaces.Matches(ctx, req.Namespace)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !managed && !finalizing(loop.instance) {
+		r.Log.V(1).Info("Ignoring Stack in a namespace which isn't managed", "namespace", req.Namespace, "name", req.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if !r.Stacks.Matches(loop.instance) {
+		r.Log.V(1).Info("Ignoring Stack of another operator instance", "class", loop.instance.GetOperatorClass())
+		return ctrl.Result{}, nil
+	}
+
+	// Reconciles of other Stacks with the same stack name and the follower wait until this one is done.
+	defer r.StackLocks.Lock(loop.instance.GetStackName())()
+
+	if err := r.refreshStack(loop); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// A paused Stack is left alone until it's deleted, so that it doesn't hang in Terminating.
+	if loop.instance.GetDeletionTimestamp() == nil {
+		paused, err := r.pauseStack(loop)
+		if paused || err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	// Check if the Stack instance is marked to be deleted, which is
 	// indicated by the deletion timestamp being set.
@@ -109,7 +180,11 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 			controllerutil.ContainsFinalizer(loop.instance, legacyFinalizer) {
 			// Remove stacksFinalizer. Once all finalizers have been
 			// removed, the object will be deleted.
-			if loop.instance.Status.StackStatus == "DELETE_COMPLETE" {
+			neverCreated, err := r.stackNeverCreated(loop)
+			if err != nil {
+				return r.handleError(loop, err)
+			}
+			if loop.instance.Status.StackStatus == "DELETE_COMPLETE" || neverCreated {
 				controllerutil.RemoveFinalizer(loop.instance, stacksFinalizer)
 				controllerutil.RemoveFinalizer(loop.instance, legacyFinalizer)
 				err := r.Update(loop.ctx, loop.instance)
@@ -125,7 +200,7 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 				err := r.deleteStack(loop)
 				if err != nil {
 					r.Log.Error(err, "Failed to delete stack")
-					return ctrl.Result{}, err
+					return r.handleError(loop, err)
 				}
 			}
 		}
@@ -139,6 +214,8 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, err
 	}
 
+	loop.forced = reconcileRequested(loop.instance)
+
 	exists, err := r.stackExists(loop)
 	if err != nil {
 		return reconcile.Result{}, err
@@ -149,20 +226,116 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		// If it is being followed, we want the same thing, just send it over to the other thread to check it in all
 		// IN_PROGRESS cases.
 		if !r.CloudFormationHelper.StackInTerminalState(loop.stack.StackStatus) {
-			r.StackFollower.SubmissionChannel <- loop.instance
-			return ctrl.Result{}, nil
+			result, err := r.cancelUpdate(loop)
+			r.StackFollower.Follow(loop.instance)
+			return result, err
+		}
+
+		if err := r.StackFollower.RecordFinishedOperation(ctx, loop.instance, loop.stack); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.dismissCancelRequest(loop); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.acknowledgeReconcileRequest(loop); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// A stack whose creation failed can only be deleted, so it's deleted and created again.
+		if stackRecreatePending(loop) {
+			if err := r.resetForRecreate(loop); err != nil {
+				return ctrl.Result{}, err
+			}
+			return r.handleError(loop, r.createStack(loop))
+		}
+
+		if stackCreateFailed(loop.stack.StackStatus) {
+			return r.recreateStack(loop)
+		}
+
+		if loop.stack.StackStatus == cfTypes.StackStatusUpdateRollbackFailed {
+			return reconcile.Result{}, r.recoverStack(loop)
+		}
+
+		if err := r.finishRecovery(loop); err != nil {
+			return reconcile.Result{}, err
 		}
 
-		return reconcile.Result{}, r.updateStack(loop)
+		if err := r.finishRecreate(loop); err != nil {
+			return reconcile.Result{}, err
+		}
+
+		if held, err := r.finishCancel(loop); held || err != nil {
+			return reconcile.Result{}, err
+		}
+
+		return r.handleError(loop, r.updateStack(loop))
+	}
+
+	if err := r.dismissCancelRequest(loop); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, r.createStack(loop)
+	if err := r.acknowledgeReconcileRequest(loop); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return r.handleError(loop, r.createStack(loop))
+}
+
+// handleError decides how to go on after changing a stack failed. Throttled requests are retried with
+// backoff while requests CloudFormation rejected and templates which can't be rendered are reported in
+// the Synced condition and only retried once the Stack changes.
+func (r *StackReconciler) handleError(loop *StackLoop, err error) (ctrl.Result, error) {
+	if err == nil {
+		return ctrl.Result{}, nil
+	}
+
+	var violation *policyViolationError
+	if coreerrors.As(err, &violation) {
+		r.Log.WithValues("stack", loop.instance.Name).Info("stack violates policy", "error", err.Error())
+		r.Recorder.Event(loop.instance, corev1.EventTypeWarning, cloudformationv1alpha1.ReasonPolicyViolation, err.Error())
+		return ctrl.Result{}, r.setCondition(loop, cloudformationv1alpha1.ConditionSynced, metav1.ConditionFalse,
+			cloudformationv1alpha1.ReasonPolicyViolation, err.Error())
+	}
+
+	var renderErr *renderError
+	if coreerrors.As(err, &renderErr) {
+		r.Log.WithValues("stack", loop.instance.Name).Info("stack could not be rendered", "error", err.Error())
+		return ctrl.Result{}, r.setCondition(loop, cloudformationv1alpha1.ConditionSynced, metav1.ConditionFalse,
+			cloudformationv1alpha1.ReasonRenderFailed, err.Error())
+	}
+
+	switch r.CloudFormationHelper.ClassifyError(err) {
+	case ErrorClassThrottling:
+		r.Log.WithValues("stack", loop.instance.Name).Info("request was throttled, retrying", "error", err.Error())
+		return ctrl.Result{Requeue: true}, nil
+	case ErrorClassAlreadyExists:
+		// The stack was created in the meantime, look it up again.
+		r.CloudFormationHelper.InvalidateStack(loop.instance)
+		return ctrl.Result{Requeue: true}, nil
+	case ErrorClassTokenAlreadyExists:
+		// The request was submitted already.
+		r.StackFollower.Follow(loop.instance)
+		return ctrl.Result{}, nil
+	case ErrorClassValidation:
+		r.Log.WithValues("stack", loop.instance.Name).Info("stack was rejected", "error", err.Error())
+		return ctrl.Result{}, r.setCondition(loop, cloudformationv1alpha1.ConditionSynced, metav1.ConditionFalse,
+			cloudformationv1alpha1.ReasonValidationError, err.Error())
+	case ErrorClassInsufficientCapabilities:
+		r.Log.WithValues("stack", loop.instance.Name).Info("stack was rejected", "error", err.Error())
+		return ctrl.Result{}, r.setCondition(loop, cloudformationv1alpha1.ConditionSynced, metav1.ConditionFalse,
+			cloudformationv1alpha1.ReasonInsufficientCapabilities, err.Error())
+	}
+	return ctrl.Result{}, err
 }
 
 func (r *StackReconciler) createStack(loop *StackLoop) error {
 	r.Log.WithValues("stack", loop.instance.Name).Info("creating stack")
 
-	if r.DryRun {
+	if loop.settings.DryRun {
 		r.Log.WithValues("stack", loop.instance.Name).Info("skipping stack creation")
 		return nil
 	}
@@ -174,6 +347,7 @@ func (r *StackReconciler) createStack(loop *StackLoop) error {
 
 	if !hasOwnership {
 		r.Log.WithValues("stack", loop.instance.Name).Info("no ownership")
+		r.recordNotOwned(loop)
 		return nil
 	}
 
@@ -183,28 +357,51 @@ func (r *StackReconciler) createStack(loop *StackLoop) error {
 		return err
 	}
 
+	templateBody, parameters, err := r.stackTemplate(loop)
+	if err != nil {
+		r.Log.WithValues("stack", loop.instance.Name).Error(err, "error compiling template")
+		return err
+	}
+
 	input := &cloudformation.CreateStackInput{
-		Capabilities: r.DefaultCapabilities,
-		StackName:    aws.String(loop.instance.Name),
-		TemplateBody: aws.String(loop.instance.Spec.Template),
-		Parameters:   r.stackParameters(loop),
-		Tags:         stackTags,
+		Capabilities:     loop.settings.Capabilities,
+		StackName:        aws.String(loop.instance.GetStackName()),
+		TemplateBody:     aws.String(templateBody),
+		Parameters:       parameters,
+		Tags:             stackTags,
+		TimeoutInMinutes: createTimeout(loop.instance),
 	}
 
 	output, err := r.CloudFormation.CreateStack(loop.ctx, input)
 	if err != nil {
+		r.recordFailure(loop, cloudformationv1alpha1.ReasonCreateStackFailed, err)
+		return err
+	}
+	r.CloudFormationHelper.InvalidateStack(loop.instance)
+
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonCreating,
+		"Creating stack %s", loop.instance.GetStackName())
+	if err := r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+		status.StackID = *output.StackId
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               cloudformationv1alpha1.ConditionSynced,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: loop.instance.Generation,
+			Reason:             cloudformationv1alpha1.ReasonSubmitted,
+			Message:            "stack creation submitted",
+		})
+	}); err != nil {
 		return err
 	}
-	loop.instance.Status.StackID = *output.StackId
 
-	r.StackFollower.SubmissionChannel <- loop.instance
+	r.StackFollower.Follow(loop.instance)
 	return nil
 }
 
 func (r *StackReconciler) updateStack(loop *StackLoop) error {
 	r.Log.WithValues("stack", loop.instance.Name).Info("updating stack")
 
-	if r.DryRun {
+	if loop.settings.DryRun {
 		r.Log.WithValues("stack", loop.instance.Name).Info("skipping stack update")
 		return nil
 	}
@@ -216,6 +413,7 @@ func (r *StackReconciler) updateStack(loop *StackLoop) error {
 
 	if !hasOwnership {
 		r.Log.WithValues("stack", loop.instance.Name).Info("no ownership")
+		r.recordNotOwned(loop)
 		return nil
 	}
 
@@ -225,30 +423,48 @@ func (r *StackReconciler) updateStack(loop *StackLoop) error {
 		return err
 	}
 
+	templateBody, parameters, err := r.stackTemplate(loop)
+	if err != nil {
+		r.Log.WithValues("stack", loop.instance.Name).Error(err, "error compiling template")
+		return err
+	}
+
 	input := &cloudformation.UpdateStackInput{
-		Capabilities: r.DefaultCapabilities,
-		StackName:    aws.String(loop.instance.Name),
-		TemplateBody: aws.String(loop.instance.Spec.Template),
-		Parameters:   r.stackParameters(loop),
+		Capabilities: loop.settings.Capabilities,
+		StackName:    aws.String(loop.instance.GetStackName()),
+		TemplateBody: aws.String(templateBody),
+		Parameters:   parameters,
 		Tags:         stackTags,
 	}
 
 	if _, err := r.CloudFormation.UpdateStack(loop.ctx, input); err != nil {
-		if strings.Contains(err.Error(), "No updates are to be performed.") {
+		if r.CloudFormationHelper.ClassifyError(err) == ErrorClassNoUpdates {
 			r.Log.WithValues("stack", loop.instance.Name).Info("stack already updated")
-			return nil
+			r.Recorder.Event(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonUpToDate,
+				"Stack is up to date")
+			return r.setCondition(loop, cloudformationv1alpha1.ConditionSynced, metav1.ConditionTrue,
+				cloudformationv1alpha1.ReasonUpToDate, "")
 		}
+		r.recordFailure(loop, cloudformationv1alpha1.ReasonUpdateStackFailed, err)
 		return err
 	}
+	r.CloudFormationHelper.InvalidateStack(loop.instance)
 
-	r.StackFollower.SubmissionChannel <- loop.instance
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonUpdating,
+		"Updating stack %s", loop.instance.GetStackName())
+	if err := r.setCondition(loop, cloudformationv1alpha1.ConditionSynced, metav1.ConditionTrue,
+		cloudformationv1alpha1.ReasonSubmitted, "stack update submitted"); err != nil {
+		return err
+	}
+
+	r.StackFollower.Follow(loop.instance)
 	return nil
 }
 
 func (r *StackReconciler) deleteStack(loop *StackLoop) error {
 	r.Log.WithValues("stack", loop.instance.Name).Info("deleting stack")
 
-	if r.DryRun {
+	if loop.settings.DryRun {
 		r.Log.WithValues("stack", loop.instance.Name).Info("skipping stack deletion")
 		return nil
 	}
@@ -260,18 +476,31 @@ func (r *StackReconciler) deleteStack(loop *StackLoop) error {
 
 	if !hasOwnership {
 		r.Log.WithValues("stack", loop.instance.Name).Info("no ownership")
+		r.recordNotOwned(loop)
+		return nil
+	}
+
+	// CloudFormation refuses to delete a stack while it's changing, so wait for it to settle first.
+	if loop.stack != nil && !r.CloudFormationHelper.StackInTerminalState(loop.stack.StackStatus) {
+		r.Log.WithValues("stack", loop.instance.Name).Info("waiting for stack to settle before deleting it")
+		r.StackFollower.Follow(loop.instance)
 		return nil
 	}
 
 	input := &cloudformation.DeleteStackInput{
-		StackName: aws.String(loop.instance.Name),
+		StackName: aws.String(loop.instance.GetStackName()),
 	}
 
 	if _, err := r.CloudFormation.DeleteStack(loop.ctx, input); err != nil {
+		r.recordFailure(loop, cloudformationv1alpha1.ReasonDeleteStackFailed, err)
 		return err
 	}
+	r.CloudFormationHelper.InvalidateStack(loop.instance)
+
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonDeleting,
+		"Deleting stack %s", loop.instance.GetStackName())
 
-	r.StackFollower.SubmissionChannel <- loop.instance
+	r.StackFollower.Follow(loop.instance)
 	return nil
 }
 
@@ -283,9 +512,6 @@ func (r *StackReconciler) getStack(loop *StackLoop, noCache bool) (*cfTypes.Stac
 		// Must use the stack ID to get details/finalization for deleted stacks
 		loop.stack, err = r.CloudFormationHelper.GetStack(loop.ctx, loop.instance)
 		if err != nil {
-			if strings.Contains(err.Error(), "does not exist") {
-				return nil, ErrStackNotFound
-			}
 			return nil, err
 		}
 	}
@@ -305,6 +531,22 @@ func (r *StackReconciler) stackExists(loop *StackLoop) (bool, error) {
 	return true, nil
 }
 
+// stackNeverCreated tells whether a Stack never got a CloudFormation stack, e.g. because its template was
+// rejected, so there's nothing to delete.
+func (r *StackReconciler) stackNeverCreated(loop *StackLoop) (bool, error) {
+	if loop.instance.Status.StackID != "" {
+		return false, nil
+	}
+	exists, err := r.stackExists(loop)
+	return !exists, err
+}
+
+// finalizing tells whether a Stack is being deleted and still has one of the operator's finalizers.
+func finalizing(instance *cloudformationv1alpha1.Stack) bool {
+	return instance.GetDeletionTimestamp() != nil &&
+		(controllerutil.ContainsFinalizer(instance, stacksFinalizer) || controllerutil.ContainsFinalizer(instance, legacyFinalizer))
+}
+
 func (r *StackReconciler) hasOwnership(loop *StackLoop) (bool, error) {
 	exists, err := r.stackExists(loop)
 	if err != nil {
@@ -319,20 +561,92 @@ func (r *StackReconciler) hasOwnership(loop *StackLoop) (bool, error) {
 		return false, err
 	}
 
+	owned := false
+	class, tagged := "", false
+	owner := ""
 	for _, tag := range cfs.Tags {
-		if *tag.Key == controllerKey && *tag.Value == controllerValue {
-			return true, nil
+		switch *tag.Key {
+		case controllerKey:
+			owned = owned || *tag.Value == controllerValue
+		case operatorClassKey:
+			class, tagged = *tag.Value, true
+		case ownerKey:
+			owner = *tag.Value
+		}
+	}
+
+	// A stack tagged with another class belongs to that class's instance, unless it was created for this
+	// very Stack, whose class was changed since. A stack without a class tag predates operator classes and
+	// is adopted by any class. Either way the next update tags it with the class of the Stack.
+	return owned && (!tagged || class == loop.instance.GetOperatorClass() || owner == string(loop.instance.UID)), nil
+}
+
+// recordNotOwned emits an Event about refusing to change a stack that isn't owned by the operator.
+func (r *StackReconciler) recordNotOwned(loop *StackLoop) {
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeWarning, cloudformationv1alpha1.ReasonNotOwned,
+		"Not changing stack %s which isn't owned by this Stack", loop.instance.GetStackName())
+}
+
+// recordFailure emits an Event about a request CloudFormation refused. Throttled requests are retried
+// and not reported.
+func (r *StackReconciler) recordFailure(loop *StackLoop, reason string, err error) {
+	if r.CloudFormationHelper.ClassifyError(err) == ErrorClassThrottling {
+		return
+	}
+	r.Recorder.Event(loop.instance, corev1.EventTypeWarning, reason, err.Error())
+}
+
+// stackTemplate returns the template body and parameters to send to CloudFormation, rendering them
+// first if the Stack asks for it. The outcome of rendering is recorded in the Stack's conditions.
+func (r *StackReconciler) stackTemplate(loop *StackLoop) (string, []cfTypes.Parameter, error) {
+	templateBody, err := loop.instance.GetTemplateBody()
+	if err != nil {
+		return "", nil, err
+	}
+	parameters := loop.instance.Spec.Parameters
+
+	if loop.instance.Spec.RenderTemplate {
+		templateBody, parameters, err = renderStack(loop.instance, templateBody, r.OperatorVariables)
+		if err != nil {
+			if statusErr := r.setCondition(loop, cloudformationv1alpha1.ConditionTemplateRendered, metav1.ConditionFalse,
+				cloudformationv1alpha1.ReasonRenderFailed, err.Error()); statusErr != nil {
+				r.Log.WithValues("stack", loop.instance.Name).Error(statusErr, "error recording render failure")
+			}
+			return "", nil, err
+		}
+		if err := r.setCondition(loop, cloudformationv1alpha1.ConditionTemplateRendered, metav1.ConditionTrue,
+			cloudformationv1alpha1.ReasonRendered, ""); err != nil {
+			return "", nil, err
 		}
 	}
 
-	return false, nil
+	return templateBody, r.stackParameters(parameters), nil
 }
 
-// stackParameters converts the parameters field on a Stack resource to CloudFormation Parameters.
-func (r *StackReconciler) stackParameters(loop *StackLoop) []cfTypes.Parameter {
+// setCondition records a condition in the Stack's status and persists it if anything changed.
+func (r *StackReconciler) setCondition(loop *StackLoop, conditionType string, status metav1.ConditionStatus, reason, message string) error {
+	return r.updateStatus(loop, func(stackStatus *cloudformationv1alpha1.StackStatus) {
+		meta.SetStatusCondition(&stackStatus.Conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             status,
+			ObservedGeneration: loop.instance.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	})
+}
+
+// updateStatus applies mutate to the Stack's status and patches the fields it changed. Fields owned by the
+// StackFollower are left untouched, so both can write the status without overwriting each other.
+func (r *StackReconciler) updateStatus(loop *StackLoop, mutate func(*cloudformationv1alpha1.StackStatus)) error {
+	return patchStackStatus(loop.ctx, r.Client, r.APIReader, reconcilerFieldManager, loop.instance, mutate)
+}
+
+// stackParameters converts the parameters of a Stack resource to CloudFormation Parameters.
+func (r *StackReconciler) stackParameters(parameters map[string]string) []cfTypes.Parameter {
 	var params []cfTypes.Parameter
-	if loop.instance.Spec.Parameters != nil {
-		for k, v := range loop.instance.Spec.Parameters {
+	if parameters != nil {
+		for k, v := range parameters {
 			params = append(params, cfTypes.Parameter{
 				ParameterKey:   aws.String(k),
 				ParameterValue: aws.String(v),
@@ -356,9 +670,15 @@ func (r *StackReconciler) stackTags(loop *StackLoop) ([]cfTypes.Tag, error) {
 			Value: aws.String(string(loop.instance.UID)),
 		},
 	}
+	if class := loop.instance.GetOperatorClass(); class != "" {
+		tags = append(tags, cfTypes.Tag{
+			Key:   aws.String(operatorClassKey),
+			Value: aws.String(class),
+		})
+	}
 
 	// default tags
-	for k, v := range r.DefaultTags {
+	for k, v := range loop.settings.Tags {
 		tags = append(tags, cfTypes.Tag{
 			Key:   aws.String(k),
 			Value: aws.String(v),
@@ -375,12 +695,48 @@ func (r *StackReconciler) stackTags(loop *StackLoop) ([]cfTypes.Tag, error) {
 		}
 	}
 
+	// tags required by the policy
+	keys := map[string]bool{}
+	for _, tag := range tags {
+		keys[*tag.Key] = true
+	}
+	if missing := loop.settings.missingTags(keys); len(missing) > 0 {
+		return nil, missingTagsError(missing)
+	}
+
 	return tags, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *StackReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&cloudformationv1alpha1.Stack{}).
-		Complete(r)
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles})
+	if r.SettingsChanged != nil {
+		b = b.Watches(&source.Channel{Source: r.SettingsChanged}, &handler.EnqueueRequestForObject{})
+	}
+	if r.Namespaces != nil && r.Namespaces.Selector != nil {
+		// Stacks are reconciled once their namespace is selected, e.g. after it was labelled.
+		b = b.Watches(source.NewKindWithCache(&corev1.Namespace{}, r.Namespaces.Cache),
+			handler.EnqueueRequestsFromMapFunc(r.stacksInNamespace), builder.WithPredicates(namespaceChanged))
+	}
+	return b.Complete(r)
+}
+
+// stacksInNamespace returns requests for all Stacks in the given Namespace.
+func (r *StackReconciler) stacksInNamespace(ns client.Object) []reconcile.Request {
+	if !r.Namespaces.listed(ns.GetName()) {
+		return nil
+	}
+
+	stacks := &cloudformationv1alpha1.StackList{}
+	if err := r.List(context.Background(), stacks, client.InNamespace(ns.GetName())); err != nil {
+		r.Log.Error(err, "Failed to list Stacks in namespace", "namespace", ns.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, len(stacks.Items))
+	for i := range stacks.Items {
+		requests[i].NamespacedName = types.NamespacedName{Namespace: ns.GetName(), Name: stacks.Items[i].Name}
+	}
+	return requests
 }
@@ -0,0 +1,1031 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+const bucketTemplate = `
+Parameters:
+  BucketName:
+    Type: String
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Ref BucketName
+Outputs:
+  BucketName:
+    Value: !Ref BucketName
+  BucketArn:
+    Value: !GetAtt [Bucket, Arn]
+`
+
+// failingTemplate fails to be created or updated.
+const failingTemplate = `
+Parameters:
+  BucketName:
+    Type: String
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Failure:
+    Type: Fake::Failure
+    Properties:
+      Reason: Bucket policy is invalid
+`
+
+// bucketTemplateObject is bucketTemplate as spec.templateObject.
+const bucketTemplateObject = `{
+  "Parameters": {"BucketName": {"Type": "String"}},
+  "Resources": {"Bucket": {"Type": "AWS::S3::Bucket", "Properties": {"BucketName": {"Ref": "BucketName"}}}},
+  "Outputs": {
+    "BucketName": {"Value": {"Ref": "BucketName"}},
+    "BucketArn": {"Value": {"Fn::GetAtt": ["Bucket", "Arn"]}}
+  }
+}`
+
+const (
+	timeout  = 10 * time.Second
+	interval = 50 * time.Millisecond
+)
+
+var _ = Describe("Stack controller", func() {
+	ctx := context.Background()
+
+	newStack := func(name string) *cloudformationv1alpha1.Stack {
+		return &cloudformationv1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: cloudformationv1alpha1.StackSpec{
+				Template:   bucketTemplate,
+				Parameters: map[string]string{"BucketName": name + "-bucket"},
+				Tags:       map[string]string{"team": "platform"},
+			},
+		}
+	}
+
+	getStack := func(name string) func() (*cloudformationv1alpha1.Stack, error) {
+		return func() (*cloudformationv1alpha1.Stack, error) {
+			instance := &cloudformationv1alpha1.Stack{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, instance)
+			return instance, err
+		}
+	}
+
+	// updateStack changes a Stack, retrying on conflicts with the operator's status updates.
+	updateStack := func(name string, mutate func(*cloudformationv1alpha1.Stack)) {
+		Eventually(func() error {
+			instance, err := getStack(name)()
+			if err != nil {
+				return err
+			}
+			mutate(instance)
+			return k8sClient.Update(ctx, instance)
+		}, timeout, interval).Should(Succeed())
+	}
+
+	stackStatus := func(name string) func() string {
+		return func() string {
+			instance, err := getStack(name)()
+			if err != nil {
+				return ""
+			}
+			return instance.Status.StackStatus
+		}
+	}
+
+	eventReasons := func(name string) func() []string {
+		return func() []string {
+			events := &corev1.EventList{}
+			Expect(k8sClient.List(ctx, events, client.InNamespace("default"))).To(Succeed())
+			var reasons []string
+			for _, event := range events.Items {
+				if event.InvolvedObject.Name == name {
+					reasons = append(reasons, event.Reason)
+				}
+			}
+			return reasons
+		}
+	}
+
+	// deleteStack deletes a Stack and ticks the fake until the finalizer is gone.
+	deleteStack := func(name string) {
+		instance, err := getStack(name)()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		Eventually(func() bool {
+			fakeCloudFormation.Tick()
+			_, err := getStack(name)()
+			return errors.IsNotFound(err)
+		}, timeout, interval).Should(BeTrue())
+	}
+
+	It("creates the stack and follows it until it's complete", func() {
+		Expect(k8sClient.Create(ctx, newStack("create"))).To(Succeed())
+
+		Eventually(stackStatus("create"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("create"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		instance, err := getStack("create")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.Outputs).To(Equal(map[string]string{
+			"BucketName": "create-bucket",
+			"BucketArn":  "create-Bucket.Arn",
+		}))
+		Expect(instance.Status.Resources).To(ConsistOf(cloudformationv1alpha1.StackResource{
+			LogicalId:  "Bucket",
+			PhysicalId: "create-Bucket",
+			Type:       "AWS::S3::Bucket",
+			Status:     "CREATE_COMPLETE",
+		}))
+		Expect(meta.IsStatusConditionTrue(instance.Status.Conditions, cloudformationv1alpha1.ConditionSynced)).To(BeTrue())
+		Expect(controllerutil.ContainsFinalizer(instance, stacksFinalizer)).To(BeTrue())
+
+		stack, ok := fakeCloudFormation.Stack("create")
+		Expect(ok).To(BeTrue())
+		Expect(instance.Status.StackID).To(Equal(aws.ToString(stack.StackId)))
+		Expect(stack.Tags).To(ContainElements(
+			cfTypes.Tag{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+			cfTypes.Tag{Key: aws.String("team"), Value: aws.String("platform")},
+		))
+		Eventually(eventReasons("create"), timeout, interval).Should(ContainElements(
+			cloudformationv1alpha1.ReasonCreating, "CreateComplete"))
+
+		deleteStack("create")
+	})
+
+	It("creates the stack from a template object like from a template", func() {
+		instance := newStack("object")
+		instance.Spec.Template = ""
+		instance.Spec.TemplateObject = &runtime.RawExtension{Raw: []byte(bucketTemplateObject)}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		Expect(k8sClient.Create(ctx, newStack("string"))).To(Succeed())
+		Eventually(stackStatus("object"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		Eventually(stackStatus("string"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+
+		// The template object is sent as JSON.
+		Expect(fakeCloudFormation.Template("object")).To(MatchJSON(bucketTemplateObject))
+		Expect(fakeCloudFormation.Template("object")).NotTo(ContainSubstring("\n"))
+
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("object"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+		Eventually(stackStatus("string"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+		object, err := getStack("object")()
+		Expect(err).NotTo(HaveOccurred())
+		str, err := getStack("string")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(object.Status.Outputs).To(HaveKeyWithValue("BucketName", "object-bucket"))
+		Expect(str.Status.Outputs).To(HaveKeyWithValue("BucketName", "string-bucket"))
+		Expect(object.Status.Outputs).To(HaveLen(len(str.Status.Outputs)))
+		Expect(object.Status.Resources).To(HaveLen(len(str.Status.Resources)))
+
+		// Replacing the template object by the same template as a string keeps the stack as it is.
+		updateStack("object", func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.TemplateObject = nil
+			instance.Spec.Template = bucketTemplate
+		})
+		Eventually(func() string { return fakeCloudFormation.Template("object") }, timeout, interval).Should(Equal(bucketTemplate))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("object"), timeout, interval).Should(Equal("UPDATE_COMPLETE"))
+		object, err = getStack("object")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(object.Status.Outputs).To(HaveKeyWithValue("BucketName", "object-bucket"))
+
+		deleteStack("object")
+		deleteStack("string")
+	})
+
+	It("updates the stack when its spec changes", func() {
+		Expect(k8sClient.Create(ctx, newStack("update"))).To(Succeed())
+		Eventually(stackStatus("update"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("update"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		updateStack("update", func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Parameters["BucketName"] = "renamed-bucket"
+		})
+
+		Eventually(stackStatus("update"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("update"), timeout, interval).Should(Equal("UPDATE_COMPLETE"))
+
+		instance, err := getStack("update")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.Outputs).To(HaveKeyWithValue("BucketName", "renamed-bucket"))
+		Expect(instance.Status.UpdatedTime.IsZero()).To(BeFalse())
+
+		deleteStack("update")
+	})
+
+	It("reports updates which were rolled back", func() {
+		Expect(k8sClient.Create(ctx, newStack("rollback"))).To(Succeed())
+		Eventually(stackStatus("rollback"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("rollback"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		updateStack("rollback", func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Template = failingTemplate
+		})
+
+		Eventually(stackStatus("rollback"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("rollback"), timeout, interval).Should(Equal("UPDATE_ROLLBACK_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(eventReasons("rollback"), timeout, interval).Should(ContainElement("UpdateRollbackComplete"))
+
+		deleteStack("rollback")
+	})
+
+	It("recovers stacks whose update rollback failed", func() {
+		Expect(k8sClient.Create(ctx, newStack("recovery"))).To(Succeed())
+		Eventually(stackStatus("recovery"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("recovery"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		updateStack("recovery", func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Template = `
+Parameters:
+  BucketName:
+    Type: String
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Failure:
+    Type: Fake::Failure
+    Properties:
+      FailRollback: true
+`
+		})
+		Eventually(stackStatus("recovery"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("recovery"), timeout, interval).Should(Equal("UPDATE_ROLLBACK_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("recovery"), timeout, interval).Should(Equal("UPDATE_ROLLBACK_FAILED"))
+
+		recovering := func() string {
+			instance, err := getStack("recovery")()
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionRecovering)
+			if condition == nil {
+				return ""
+			}
+			return condition.Reason
+		}
+		Eventually(recovering, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonRecoveryDisabled))
+		Consistently(eventReasons("recovery"), 300*time.Millisecond, interval).ShouldNot(ContainElement(cloudformationv1alpha1.ReasonRecovered))
+
+		// Enabling recovery together with a fixed template continues the rollback and retries the update.
+		Eventually(func() error {
+			instance, err := getStack("recovery")()
+			if err != nil {
+				return err
+			}
+			instance.Spec.Template = bucketTemplate
+			instance.Spec.Parameters["BucketName"] = "recovered-bucket"
+			instance.Spec.Recovery = &cloudformationv1alpha1.StackRecovery{ContinueUpdateRollback: true}
+			return k8sClient.Update(ctx, instance)
+		}, timeout, interval).Should(Succeed())
+		Eventually(stackStatus("recovery"), timeout, interval).Should(Equal("UPDATE_ROLLBACK_IN_PROGRESS"))
+		Eventually(recovering, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonContinueUpdateRollback))
+		fakeCloudFormation.Tick()
+		Eventually(recovering, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonRecovered))
+		Eventually(stackStatus("recovery"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("recovery"), timeout, interval).Should(Equal("UPDATE_COMPLETE"))
+
+		instance, err := getStack("recovery")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.Outputs).To(HaveKeyWithValue("BucketName", "recovered-bucket"))
+		Expect(eventReasons("recovery")()).To(ContainElements(
+			cloudformationv1alpha1.ReasonContinueUpdateRollback, cloudformationv1alpha1.ReasonRecovered))
+
+		deleteStack("recovery")
+	})
+
+	It("recreates stacks whose creation failed", func() {
+		instance := newStack("recreate")
+		instance.Spec.Template = failingTemplate
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		Eventually(stackStatus("recreate"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("recreate"), timeout, interval).Should(Equal("ROLLBACK_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		// The failed stack may already be deleted by the time its status is seen, as only the deletion
+		// waits for the RecreateDelay.
+		Eventually(func() string {
+			instance, _ := getStack("recreate")()
+			return instance.Status.FailureReason
+		}, timeout, interval).Should(Equal("Failure: Bucket policy is invalid"))
+		Eventually(eventReasons("recreate"), timeout, interval).Should(ContainElement("RollbackComplete"))
+
+		// The template is fixed before the failed stack is gone, so that it's created successfully.
+		updateStack("recreate", func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Template = bucketTemplate
+		})
+		Eventually(stackStatus("recreate"), timeout, interval).Should(Equal("DELETE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("recreate"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(func() string {
+			instance, err := getStack("recreate")()
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionRecreating)
+			if condition == nil {
+				return ""
+			}
+			return condition.Reason
+		}, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonRecreated))
+		Eventually(stackStatus("recreate"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		instance, err := getStack("recreate")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.FailureReason).To(BeEmpty())
+		Expect(instance.Status.RecreateAttempts).To(BeZero())
+		Expect(eventReasons("recreate")()).To(ContainElements(cloudformationv1alpha1.ReasonDeletingFailedStack,
+			cloudformationv1alpha1.ReasonRecreating, cloudformationv1alpha1.ReasonRecreated))
+
+		deleteStack("recreate")
+	})
+
+	It("stops recreating a stack after its recreate attempts", func() {
+		instance := newStack("exhausted")
+		instance.Spec.Template = failingTemplate
+		instance.Spec.Recovery = &cloudformationv1alpha1.StackRecovery{RecreateAttempts: aws.Int32(1)}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+
+		Eventually(func() string {
+			fakeCloudFormation.Tick()
+			instance, err := getStack("exhausted")()
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionRecreating)
+			if condition == nil {
+				return ""
+			}
+			return condition.Reason
+		}, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonRecreateAttemptsExhausted))
+
+		creates := fakeCloudFormation.Calls("CreateStack")
+		Consistently(func() int {
+			fakeCloudFormation.Tick()
+			return fakeCloudFormation.Calls("CreateStack")
+		}, 500*time.Millisecond, interval).Should(Equal(creates))
+
+		instance, err := getStack("exhausted")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.StackStatus).To(Equal("ROLLBACK_COMPLETE"))
+		Expect(instance.Status.RecreateAttempts).To(Equal(int32(1)))
+		Expect(instance.Status.FailureReason).To(Equal("Failure: Bucket policy is invalid"))
+		Expect(eventReasons("exhausted")()).To(ContainElement(cloudformationv1alpha1.ReasonRecreateAttemptsExhausted))
+
+		deleteStack("exhausted")
+	})
+
+	It("deletes the stack before the Stack is gone", func() {
+		Expect(k8sClient.Create(ctx, newStack("delete"))).To(Succeed())
+		Eventually(stackStatus("delete"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("delete"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		instance, err := getStack("delete")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+
+		Eventually(stackStatus("delete"), timeout, interval).Should(Equal("DELETE_IN_PROGRESS"))
+		Consistently(func() error {
+			_, err := getStack("delete")()
+			return err
+		}, 300*time.Millisecond, interval).Should(Succeed())
+
+		fakeCloudFormation.Tick()
+		Eventually(func() bool {
+			_, err := getStack("delete")()
+			return errors.IsNotFound(err)
+		}, timeout, interval).Should(BeTrue())
+
+		stack, ok := fakeCloudFormation.Stack("delete")
+		Expect(ok).To(BeTrue())
+		Expect(stack.StackStatus).To(Equal(cfTypes.StackStatusDeleteComplete))
+	})
+
+	It("reports templates CloudFormation rejects", func() {
+		instance := newStack("invalid")
+		instance.Spec.Parameters["Unknown"] = "value"
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+
+		Eventually(func() string {
+			instance, err := getStack("invalid")()
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionSynced)
+			if condition == nil || condition.Status != metav1.ConditionFalse {
+				return ""
+			}
+			return condition.Reason
+		}, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonValidationError))
+		Eventually(eventReasons("invalid"), timeout, interval).Should(ContainElement(cloudformationv1alpha1.ReasonCreateStackFailed))
+
+		_, ok := fakeCloudFormation.Stack("invalid")
+		Expect(ok).To(BeFalse())
+
+		deleteStack("invalid")
+	})
+
+	It("reports templates which can't be rendered until the Stack changes", func() {
+		instance := newStack("unrendered")
+		instance.Spec.RenderTemplate = true
+		instance.Spec.Parameters["BucketName"] = "{{ .Stack.Labels.missing }}"
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+
+		syncedReason := func() string {
+			instance, err := getStack("unrendered")()
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionSynced)
+			if condition == nil || condition.Status != metav1.ConditionFalse {
+				return ""
+			}
+			return condition.Reason
+		}
+		Eventually(syncedReason, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonRenderFailed))
+		_, ok := fakeCloudFormation.Stack("unrendered")
+		Expect(ok).To(BeFalse())
+
+		// Rendering fails the same way until the Stack changes, so the Stack isn't requeued.
+		instance, err := getStack("unrendered")()
+		Expect(err).NotTo(HaveOccurred())
+		reconciler := &StackReconciler{
+			Client:               k8sClient,
+			APIReader:            k8sClient,
+			Log:                  ctrl.Log.WithName("test"),
+			CloudFormationHelper: &CloudFormationHelper{CloudFormation: fakeCloudFormation},
+		}
+		result, err := reconciler.handleError(&StackLoop{ctx: ctx, instance: instance}, &renderError{message: "failed"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+
+		updateStack("unrendered", func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Parameters["BucketName"] = "{{ .Stack.Name }}-bucket"
+		})
+		Eventually(func() bool {
+			fakeCloudFormation.Tick()
+			return stackStatus("unrendered")() == "CREATE_COMPLETE"
+		}, timeout, interval).Should(BeTrue())
+		stack, ok := fakeCloudFormation.Stack("unrendered")
+		Expect(ok).To(BeTrue())
+		Expect(stack.Parameters).To(ConsistOf(cfTypes.Parameter{
+			ParameterKey:   aws.String("BucketName"),
+			ParameterValue: aws.String("unrendered-bucket"),
+		}))
+
+		deleteStack("unrendered")
+	})
+
+	It("applies the policy and defaults of a reloaded config file", func() {
+		// writeConfig replaces the config file at once, so that the watcher never reads it half written.
+		writeConfig := func(config string) {
+			Expect(ioutil.WriteFile(operatorConfigFile+".tmp", []byte(emptyOperatorConfig+config), 0644)).To(Succeed())
+			Expect(os.Rename(operatorConfigFile+".tmp", operatorConfigFile)).To(Succeed())
+		}
+		defer func() {
+			writeConfig("")
+			Eventually(func() []string { return operatorSettings.For("default").RequiredTags }, timeout, interval).Should(BeEmpty())
+		}()
+
+		writeConfig(`
+namespaces:
+  default:
+    requiredTags: [cost-center]
+`)
+		Eventually(func() []string { return operatorSettings.For("default").RequiredTags }, timeout, interval).
+			Should(ConsistOf("cost-center"))
+
+		Expect(k8sClient.Create(ctx, newStack("settings"))).To(Succeed())
+		Eventually(func() string {
+			instance, err := getStack("settings")()
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionSynced)
+			if condition == nil || condition.Status != metav1.ConditionFalse {
+				return ""
+			}
+			return condition.Reason
+		}, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonPolicyViolation))
+		_, ok := fakeCloudFormation.Stack("settings")
+		Expect(ok).To(BeFalse())
+
+		// The Stack is reconciled again as soon as the config file supplies the missing tag.
+		writeConfig(`
+namespaces:
+  default:
+    requiredTags: [cost-center]
+    tags:
+      cost-center: "1234"
+`)
+		Eventually(func() map[string]string {
+			stack, ok := fakeCloudFormation.Stack("settings")
+			if !ok {
+				return nil
+			}
+			tags := map[string]string{}
+			for _, tag := range stack.Tags {
+				tags[*tag.Key] = *tag.Value
+			}
+			return tags
+		}, timeout, interval).Should(HaveKeyWithValue("cost-center", "1234"))
+
+		deleteStack("settings")
+	})
+
+	It("only manages Stacks in selected namespaces", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "relabelled",
+			Labels: map[string]string{ignoredNamespaceLabel: "true"},
+		}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+
+		instance := newStack("relabelled")
+		instance.Namespace = ns.Name
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		Consistently(func() bool {
+			_, ok := fakeCloudFormation.Stack("relabelled")
+			return ok
+		}, time.Second, interval).Should(BeFalse())
+
+		// The Stack is reconciled as soon as its namespace is selected.
+		delete(ns.Labels, ignoredNamespaceLabel)
+		Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+		Eventually(func() bool {
+			_, ok := fakeCloudFormation.Stack("relabelled")
+			return ok
+		}, timeout, interval).Should(BeTrue())
+		Eventually(func() cfTypes.StackStatus {
+			fakeCloudFormation.Tick()
+			stack, _ := fakeCloudFormation.Stack("relabelled")
+			return stack.StackStatus
+		}, timeout, interval).Should(Equal(cfTypes.StackStatusCreateComplete))
+
+		// Changes are ignored once the namespace is deselected again.
+		ns.Labels = map[string]string{ignoredNamespaceLabel: "true"}
+		Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+		Eventually(func() error {
+			key := types.NamespacedName{Namespace: ns.Name, Name: instance.Name}
+			if err := k8sClient.Get(ctx, key, instance); err != nil {
+				return err
+			}
+			instance.Spec.Parameters["BucketName"] = "ignored-bucket"
+			return k8sClient.Update(ctx, instance)
+		}, timeout, interval).Should(Succeed())
+		Consistently(func() cfTypes.StackStatus {
+			stack, _ := fakeCloudFormation.Stack("relabelled")
+			return stack.StackStatus
+		}, time.Second, interval).Should(Equal(cfTypes.StackStatusCreateComplete))
+
+		// A deleted Stack is still finalized.
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		Eventually(func() bool {
+			fakeCloudFormation.Tick()
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: ns.Name, Name: instance.Name}, instance)
+			return errors.IsNotFound(err)
+		}, timeout, interval).Should(BeTrue())
+		stack, _ := fakeCloudFormation.Stack("relabelled")
+		Expect(stack.StackStatus).To(Equal(cfTypes.StackStatusDeleteComplete))
+	})
+
+	It("leaves Stacks and stacks of other operator classes alone", func() {
+		instance := newStack("classified")
+		instance.Spec.OperatorClass = "sandbox"
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		Consistently(func() bool {
+			_, ok := fakeCloudFormation.Stack("classified")
+			return ok
+		}, time.Second, interval).Should(BeFalse())
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+
+		// A stack created by an instance of another class isn't taken over by a Stack without a class.
+		_, err := fakeCloudFormation.CreateStack(ctx, &cloudformation.CreateStackInput{
+			StackName:    aws.String("classified-stack"),
+			TemplateBody: aws.String(bucketTemplate),
+			Parameters: []cfTypes.Parameter{
+				{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("classified-bucket")},
+			},
+			Tags: []cfTypes.Tag{
+				{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+				{Key: aws.String(operatorClassKey), Value: aws.String("sandbox")},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		fakeCloudFormation.Tick()
+
+		Expect(k8sClient.Create(ctx, newStack("classified-stack"))).To(Succeed())
+		Eventually(eventReasons("classified-stack"), timeout, interval).Should(ContainElement(cloudformationv1alpha1.ReasonNotOwned))
+		Expect(fakeCloudFormation.Template("classified-stack")).To(Equal(bucketTemplate))
+
+		instance, err = getStack("classified-stack")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		Eventually(func() error {
+			instance, err := getStack("classified-stack")()
+			if err != nil {
+				return client.IgnoreNotFound(err)
+			}
+			controllerutil.RemoveFinalizer(instance, stacksFinalizer)
+			return k8sClient.Update(ctx, instance)
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("takes over the stack of a Stack whose class was changed", func() {
+		// The Stack was created for an instance of another class, which created its stack.
+		instance := newStack("reclassified")
+		instance.Spec.OperatorClass = "sandbox"
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		_, err := fakeCloudFormation.CreateStack(ctx, &cloudformation.CreateStackInput{
+			StackName:    aws.String("reclassified"),
+			TemplateBody: aws.String(bucketTemplate),
+			Parameters: []cfTypes.Parameter{
+				{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("reclassified-bucket")},
+			},
+			Tags: []cfTypes.Tag{
+				{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+				{Key: aws.String(ownerKey), Value: aws.String(string(instance.UID))},
+				{Key: aws.String(operatorClassKey), Value: aws.String("sandbox")},
+				{Key: aws.String("team"), Value: aws.String("platform")},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		fakeCloudFormation.Tick()
+
+		// Without the webhook the class can still be changed, which hands the Stack over to this instance.
+		updateStack("reclassified", func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.OperatorClass = ""
+		})
+		Eventually(func() []cfTypes.Tag {
+			fakeCloudFormation.Tick()
+			stack, _ := fakeCloudFormation.Stack("reclassified")
+			return stack.Tags
+		}, timeout, interval).ShouldNot(ContainElement(
+			cfTypes.Tag{Key: aws.String(operatorClassKey), Value: aws.String("sandbox")}))
+		Eventually(stackStatus("reclassified"), timeout, interval).Should(Equal("UPDATE_COMPLETE"))
+		Expect(eventReasons("reclassified")()).NotTo(ContainElement(cloudformationv1alpha1.ReasonNotOwned))
+
+		deleteStack("reclassified")
+		stack, _ := fakeCloudFormation.Stack("reclassified")
+		Expect(stack.StackStatus).To(Equal(cfTypes.StackStatusDeleteComplete))
+	})
+
+	It("never calls CloudFormation concurrently for the same stack", func() {
+		// Slow calls widen the window for the reconciler and the follower to overlap.
+		fakeCloudFormation.SetLatency(10 * time.Millisecond)
+		defer fakeCloudFormation.SetLatency(0)
+
+		names := []string{"concurrent-1", "concurrent-2", "concurrent-3", "concurrent-4", "concurrent-5", "concurrent-6"}
+		for _, name := range names {
+			Expect(k8sClient.Create(ctx, newStack(name))).To(Succeed())
+		}
+		allInStatus := func(status string) func() bool {
+			return func() bool {
+				fakeCloudFormation.Tick()
+				for _, name := range names {
+					if stackStatus(name)() != status {
+						return false
+					}
+				}
+				return true
+			}
+		}
+		Eventually(allInStatus("CREATE_COMPLETE"), timeout, interval).Should(BeTrue())
+
+		// Every Stack is updated twice in a row while the follower is still polling the first update.
+		for _, bucket := range []string{"first", "second"} {
+			for _, name := range names {
+				Eventually(func() error {
+					instance, err := getStack(name)()
+					if err != nil {
+						return err
+					}
+					instance.Spec.Parameters["BucketName"] = name + "-" + bucket
+					return k8sClient.Update(ctx, instance)
+				}, timeout, interval).Should(Succeed())
+			}
+			fakeCloudFormation.Tick()
+		}
+		Eventually(func() bool {
+			if !allInStatus("UPDATE_COMPLETE")() {
+				return false
+			}
+			for _, name := range names {
+				stack, _ := fakeCloudFormation.Stack(name)
+				if aws.ToString(stack.Parameters[0].ParameterValue) != name+"-second" {
+					return false
+				}
+			}
+			return true
+		}, timeout, interval).Should(BeTrue())
+
+		for _, name := range names {
+			Expect(fakeCloudFormation.Overlaps(name)).To(BeZero(), name)
+		}
+		for _, name := range names {
+			deleteStack(name)
+		}
+	})
+
+	It("pauses, reconciles and refreshes Stacks on request", func() {
+		Expect(k8sClient.Create(ctx, newStack("operational"))).To(Succeed())
+		Eventually(stackStatus("operational"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("operational"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		updateStack := func(mutate func(*cloudformationv1alpha1.Stack)) {
+			Eventually(func() error {
+				instance, err := getStack("operational")()
+				if err != nil {
+					return err
+				}
+				if instance.Annotations == nil {
+					instance.Annotations = map[string]string{}
+				}
+				mutate(instance)
+				return k8sClient.Update(ctx, instance)
+			}, timeout, interval).Should(Succeed())
+		}
+		conditionReason := func() string {
+			instance, err := getStack("operational")()
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionPaused)
+			if condition == nil {
+				return ""
+			}
+			return condition.Reason
+		}
+		bucketName := func() string {
+			stack, _ := fakeCloudFormation.Stack("operational")
+			return aws.ToString(stack.Parameters[0].ParameterValue)
+		}
+
+		// A paused Stack isn't updated, but its status can still be refreshed.
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Annotations[cloudformationv1alpha1.PausedAnnotation] = "true"
+		})
+		Eventually(conditionReason, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonPaused))
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Parameters["BucketName"] = "paused-bucket"
+			instance.Annotations[cloudformationv1alpha1.RefreshRequestAnnotation] = "refresh-1"
+		})
+		Eventually(func() string {
+			instance, _ := getStack("operational")()
+			return instance.Status.LastHandledRefreshRequest
+		}, timeout, interval).Should(Equal("refresh-1"))
+		Consistently(bucketName, 300*time.Millisecond, interval).Should(Equal("operational-bucket"))
+
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			delete(instance.Annotations, cloudformationv1alpha1.PausedAnnotation)
+		})
+		Eventually(conditionReason, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonResumed))
+		Eventually(stackStatus("operational"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("operational"), timeout, interval).Should(Equal("UPDATE_COMPLETE"))
+		Expect(bucketName()).To(Equal("paused-bucket"))
+
+		// Changing the reconcile request calls UpdateStack again although nothing changed.
+		updates := fakeCloudFormation.Calls("UpdateStack")
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Annotations[cloudformationv1alpha1.ReconcileRequestAnnotation] = "reconcile-1"
+		})
+		Eventually(func() string {
+			instance, _ := getStack("operational")()
+			return instance.Status.LastHandledReconcileRequest
+		}, timeout, interval).Should(Equal("reconcile-1"))
+		Eventually(func() int { return fakeCloudFormation.Calls("UpdateStack") }, timeout, interval).Should(BeNumerically(">", updates))
+		Eventually(eventReasons("operational"), timeout, interval).Should(ContainElements(
+			cloudformationv1alpha1.ReasonPaused, cloudformationv1alpha1.ReasonResumed,
+			cloudformationv1alpha1.ReasonRefreshRequested, cloudformationv1alpha1.ReasonReconcileRequested))
+
+		// Deleting a paused Stack deletes its stack instead of leaving the Stack in Terminating.
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("operational"), timeout, interval).Should(Equal("UPDATE_COMPLETE"))
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Annotations[cloudformationv1alpha1.PausedAnnotation] = "true"
+		})
+		Eventually(conditionReason, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonPaused))
+		deleteStack("operational")
+		stack, _ := fakeCloudFormation.Stack("operational")
+		Expect(stack.StackStatus).To(Equal(cfTypes.StackStatusDeleteComplete))
+	})
+
+	It("cancels updates which time out or on request", func() {
+		instance := newStack("hung")
+		instance.Spec.Timeouts = &cloudformationv1alpha1.StackTimeouts{
+			Create: &metav1.Duration{Duration: 90 * time.Second},
+			Update: &metav1.Duration{Duration: time.Second},
+		}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		Eventually(stackStatus("hung"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		stack, _ := fakeCloudFormation.Stack("hung")
+		Expect(stack.TimeoutInMinutes).To(Equal(aws.Int32(2)))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("hung"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		updateStack := func(mutate func(*cloudformationv1alpha1.Stack)) {
+			Eventually(func() error {
+				instance, err := getStack("hung")()
+				if err != nil {
+					return err
+				}
+				mutate(instance)
+				return k8sClient.Update(ctx, instance)
+			}, timeout, interval).Should(Succeed())
+		}
+		cancelling := func() string {
+			instance, err := getStack("hung")()
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionCancelling)
+			if condition == nil {
+				return ""
+			}
+			return condition.Reason
+		}
+		bucketName := func() string {
+			stack, _ := fakeCloudFormation.Stack("hung")
+			return aws.ToString(stack.Parameters[0].ParameterValue)
+		}
+
+		// The update is never ticked on, so it times out and is rolled back.
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Parameters["BucketName"] = "timed-out-bucket"
+		})
+		Eventually(stackStatus("hung"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		Eventually(stackStatus("hung"), timeout, interval).Should(Equal("UPDATE_ROLLBACK_IN_PROGRESS"))
+		Eventually(cancelling, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonUpdateTimedOut))
+		fakeCloudFormation.Tick()
+		Eventually(cancelling, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonCancelled))
+		Expect(stackStatus("hung")()).To(Equal("UPDATE_ROLLBACK_COMPLETE"))
+		// The cancelled update isn't submitted again.
+		Consistently(bucketName, 500*time.Millisecond, interval).Should(Equal("hung-bucket"))
+
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Timeouts.Update = nil
+			instance.Spec.Parameters["BucketName"] = "cancelled-bucket"
+		})
+		Eventually(stackStatus("hung"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Annotations = map[string]string{cloudformationv1alpha1.CancelRequestAnnotation: "cancel-1"}
+		})
+		Eventually(stackStatus("hung"), timeout, interval).Should(Equal("UPDATE_ROLLBACK_IN_PROGRESS"))
+		Eventually(cancelling, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonCancelRequested))
+		instance, err := getStack("hung")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.LastHandledCancelRequest).To(Equal("cancel-1"))
+		fakeCloudFormation.Tick()
+		Eventually(cancelling, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonCancelled))
+		Expect(bucketName()).To(Equal("hung-bucket"))
+
+		// A change made while the cancelled update was in progress is submitted once it was rolled back.
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Parameters["BucketName"] = "stuck-bucket"
+		})
+		Eventually(stackStatus("hung"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Spec.Parameters["BucketName"] = "fixed-bucket"
+		})
+		updateStack(func(instance *cloudformationv1alpha1.Stack) {
+			instance.Annotations = map[string]string{cloudformationv1alpha1.CancelRequestAnnotation: "cancel-2"}
+		})
+		Eventually(cancelling, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonCancelRequested))
+		fakeCloudFormation.Tick()
+		Eventually(bucketName, timeout, interval).Should(Equal("fixed-bucket"))
+
+		Eventually(eventReasons("hung"), timeout, interval).Should(ContainElements(
+			cloudformationv1alpha1.ReasonUpdateTimedOut, cloudformationv1alpha1.ReasonCancelRequested,
+			cloudformationv1alpha1.ReasonCancelled, "UpdateRollbackComplete"))
+
+		deleteStack("hung")
+	})
+
+	It("lets operator instances of any class adopt stacks without a class", func() {
+		_, err := fakeCloudFormation.CreateStack(ctx, &cloudformation.CreateStackInput{
+			StackName:    aws.String("unclassified"),
+			TemplateBody: aws.String(bucketTemplate),
+			Parameters: []cfTypes.Parameter{
+				{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("unclassified-bucket")},
+			},
+			Tags: []cfTypes.Tag{
+				{Key: aws.String(controllerKey), Value: aws.String(controllerValue)},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		fakeCloudFormation.Tick()
+
+		// The Stacks are only passed to hasOwnership, so that the operator under test, which has no class,
+		// doesn't reconcile them.
+		reconciler := &StackReconciler{CloudFormationHelper: &CloudFormationHelper{CloudFormation: fakeCloudFormation}}
+		hasOwnership := func(class string) bool {
+			instance := newStack("unclassified")
+			instance.Spec.OperatorClass = class
+			owned, err := reconciler.hasOwnership(&StackLoop{ctx: ctx, instance: instance})
+			Expect(err).NotTo(HaveOccurred())
+			return owned
+		}
+		Expect(hasOwnership("sandbox")).To(BeTrue())
+		Expect(hasOwnership("")).To(BeTrue())
+
+		_, err = fakeCloudFormation.DeleteStack(ctx, &cloudformation.DeleteStackInput{StackName: aws.String("unclassified")})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("leaves stacks it doesn't own alone", func() {
+		_, err := fakeCloudFormation.CreateStack(ctx, &cloudformation.CreateStackInput{
+			StackName:    aws.String("foreign"),
+			TemplateBody: aws.String(bucketTemplate),
+			Parameters: []cfTypes.Parameter{
+				{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("foreign-bucket")},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		fakeCloudFormation.Tick()
+
+		Expect(k8sClient.Create(ctx, newStack("foreign"))).To(Succeed())
+		Eventually(eventReasons("foreign"), timeout, interval).Should(ContainElement(cloudformationv1alpha1.ReasonNotOwned))
+
+		stack, ok := fakeCloudFormation.Stack("foreign")
+		Expect(ok).To(BeTrue())
+		Expect(stack.StackStatus).To(Equal(cfTypes.StackStatusCreateComplete))
+		Expect(stack.Parameters).To(ConsistOf(
+			cfTypes.Parameter{ParameterKey: aws.String("BucketName"), ParameterValue: aws.String("foreign-bucket")},
+		))
+		Expect(fakeCloudFormation.Template("foreign")).To(Equal(bucketTemplate))
+
+		// A Stack whose stack isn't owned never sees it deleted, so its finalizer is removed by hand.
+		instance, err := getStack("foreign")()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		Eventually(func() error {
+			instance, err := getStack("foreign")()
+			if err != nil {
+				return client.IgnoreNotFound(err)
+			}
+			controllerutil.RemoveFinalizer(instance, stacksFinalizer)
+			return k8sClient.Update(ctx, instance)
+		}, timeout, interval).Should(Succeed())
+
+		stack, _ = fakeCloudFormation.Stack("foreign")
+		Expect(stack.StackStatus).To(Equal(cfTypes.StackStatusCreateComplete))
+	})
+})
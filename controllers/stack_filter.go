@@ -0,0 +1,56 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+// operatorClassKey tags CloudFormation stacks with the operator class of their Stack, so that an operator
+// instance of another class never takes them over.
+const operatorClassKey = "cloudformation.linki.space/operator-class"
+
+// StackFilter selects the Stacks an operator instance manages, so that several instances can run in one
+// cluster. Stacks of other instances are neither reconciled nor followed, a nil StackFilter selects all Stacks.
+type StackFilter struct {
+	// OperatorClass of the Stacks to manage, only Stacks without a class are managed if empty.
+	OperatorClass string
+	// Selector of the labels of the Stacks to manage, e.g. to shard Stacks across instances. All if nil.
+	Selector labels.Selector
+}
+
+// Matches returns whether the Stack is managed.
+func (f *StackFilter) Matches(instance *cloudformationv1alpha1.Stack) bool {
+	if f == nil {
+		return true
+	}
+	if instance.GetOperatorClass() != f.OperatorClass {
+		return false
+	}
+	return f.Selector == nil || f.Selector.Matches(labels.Set(instance.Labels))
+}
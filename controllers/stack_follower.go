@@ -27,167 +27,489 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
 	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/go-logr/logr"
-	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"reflect"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sync"
-	"time"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+const (
+	defaultPollInterval    = 5 * time.Second
+	defaultMaxPollInterval = time.Minute
+	defaultPollJitter      = 0.1
+	pollBackoffFactor      = 2
 )
 
-// StackFollower ensures a Stack object is monitored until it reaches a terminal state
+var _ manager.LeaderElectionRunnable = &StackFollower{}
+
+// StackFollower ensures a Stack object is monitored until it reaches a terminal state.
+// It runs as a manager Runnable and polls followed stacks from a rate-limited workqueue keyed by the Stack's UID.
 type StackFollower struct {
 	client.Client
-	Log                  logr.Logger
+	Log logr.Logger
+	// APIReader reads the latest version of a Stack after a conflicting status update.
+	APIReader            client.Reader
+	Recorder             record.EventRecorder
 	CloudFormationHelper *CloudFormationHelper
-	SubmissionChannel    chan *cloudformationv1alpha1.Stack
-	// StackID -> Kube Stack object
-	mapPollingList sync.Map
+	// PollInterval is how long to wait before polling a stack after an operation started or its status changed,
+	// 5 seconds if not positive.
+	PollInterval time.Duration
+	// MaxPollInterval caps the interval, which doubles every time a stack is polled without a change in its status.
+	MaxPollInterval time.Duration
+	// PollJitter adds up to this fraction of the interval to spread out polling stacks.
+	PollJitter float64
+	// ResyncInterval is how often the status of all Stacks is refreshed, 0 disables resyncing.
+	ResyncInterval time.Duration
+	// Namespaces whose Stacks are followed, nil for all.
+	Namespaces *NamespaceFilter
+	// Stacks followed by this instance, nil for all.
+	Stacks *StackFilter
+	// StackLocks are shared with the StackReconciler, so that a stack isn't polled while an operation on it
+	// is being submitted.
+	StackLocks *StackLocks
+
+	queue workqueue.RateLimitingInterface
+	// UID -> namespaced name of the Stack object
+	following sync.Map
+	// UID -> *pollState of the Stack object
+	polls sync.Map
+	// UIDs of followed Stacks which were asked to be followed again while being polled
+	refollow sync.Map
 }
 
-func (f *StackFollower) Receiver() {
+// pollState tracks the adaptive polling interval of a followed stack.
+type pollState struct {
+	interval    time.Duration
+	stackStatus cfTypes.StackStatus
+}
 
-	for {
-		toBeFollowed := <-f.SubmissionChannel
-		f.Log.Info("Received follow request", "UID", toBeFollowed.UID, "Stack ID", toBeFollowed.Status.StackID)
-		if !f.BeingFollowed(toBeFollowed.Status.StackID) {
-			f.startFollowing(toBeFollowed)
-		}
-		_ = f.UpdateStackStatus(context.TODO(), toBeFollowed)
+// NewStackFollower creates a StackFollower which needs to be added to the manager to start following stacks.
+func NewStackFollower(c client.Client, log logr.Logger, helper *CloudFormationHelper) *StackFollower {
+	return &StackFollower{
+		Client:               c,
+		Log:                  log,
+		CloudFormationHelper: helper,
+		PollInterval:         defaultPollInterval,
+		MaxPollInterval:      defaultMaxPollInterval,
+		PollJitter:           defaultPollJitter,
+		queue:                workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "stack-follower"),
+	}
+}
+
+// Follow starts following the given Stack until its CloudFormation stack reaches a terminal state.
+// It never blocks and following an already followed Stack keeps polling it at its current interval.
+func (f *StackFollower) Follow(instance *cloudformationv1alpha1.Stack) {
+	f.Log.Info("Received follow request", "UID", instance.UID, "Stack ID", instance.Status.StackID)
+	if _, followed := f.following.LoadOrStore(instance.UID, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}); followed {
+		// A new operation may have started just as the stack reached a terminal state, so poll it once more.
+		f.refollow.Store(instance.UID, true)
+		return
 	}
+	f.Log.Info("Now following Stack", "UID", instance.UID)
+	followedStacks.Inc()
+	f.queue.Add(instance.UID)
 }
 
-// Identify if the follower is actively working this one.
-func (f *StackFollower) BeingFollowed(stackId string) bool {
-	_, followed := f.mapPollingList.Load(stackId)
-	f.Log.Info("Following Stack", "StackID", stackId, "Following", followed)
+// BeingFollowed identifies if the follower is actively working the Stack with the given UID.
+func (f *StackFollower) BeingFollowed(uid types.UID) bool {
+	_, followed := f.following.Load(uid)
 	return followed
 }
 
-// Identify if the follower is actively working this one.
-func (f *StackFollower) startFollowing(stack *cloudformationv1alpha1.Stack) {
-	f.mapPollingList.Store(stack.Status.StackID, stack)
-	f.Log.Info("Now following Stack", "StackID", stack.Status.StackID)
+func (f *StackFollower) stopFollowing(uid types.UID) {
+	if value, followed := f.following.Load(uid); followed {
+		key := value.(types.NamespacedName)
+		stackNextPoll.DeleteLabelValues(key.Namespace, key.Name)
+		followedStacks.Dec()
+	}
+	f.following.Delete(uid)
+	f.polls.Delete(uid)
+	f.refollow.Delete(uid)
+	f.queue.Forget(uid)
+	f.Log.Info("Stopped following Stack", "UID", uid)
 }
 
-// Identify if the follower is actively working this one.
-func (f *StackFollower) stopFollowing(stackId string) {
-	f.mapPollingList.Delete(stackId)
-	f.Log.Info("Stopped following Stack", "StackID", stackId)
+// NeedLeaderElection implements manager.LeaderElectionRunnable, so that only the elected leader
+// polls CloudFormation and writes the status of followed stacks.
+func (f *StackFollower) NeedLeaderElection() bool {
+	return true
 }
 
-// Allow passing a current/recent fetch of the stack object to the method (optionally)
-func (f *StackFollower) UpdateStackStatus(ctx context.Context, instance *cloudformationv1alpha1.Stack, stack ...*cfTypes.Stack) error {
-	var err error
-	var cfs *cfTypes.Stack
-	update := false
+// Start processes the workqueue until the context is cancelled. It's only called once this
+// instance became the leader, so it starts by rebuilding the follow list.
+func (f *StackFollower) Start(ctx context.Context) error {
+	f.Log.Info("Starting stack follower")
 
-	if len(stack) > 0 {
-		cfs = stack[0]
+	if err := f.resumeFollowing(ctx); err != nil {
+		f.Log.Error(err, "Failed to resume following stacks")
 	}
-	if cfs == nil {
-		cfs, err = f.CloudFormationHelper.GetStack(ctx, instance)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for f.processNextItem(ctx) {
+		}
+	}()
+
+	if f.ResyncInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.resyncLoop(ctx)
+		}()
+	}
+
+	<-ctx.Done()
+	f.Log.Info("Shutting down stack follower")
+	f.queue.ShutDown()
+	wg.Wait()
+	return nil
+}
+
+// resumeFollowing follows all Stacks whose last known status isn't terminal, e.g. because the
+// operator restarted or another instance was following them before it lost its leadership.
+func (f *StackFollower) resumeFollowing(ctx context.Context) error {
+	return f.followStacks(ctx, func(instance *cloudformationv1alpha1.Stack) bool {
+		return !f.CloudFormationHelper.StackInTerminalState(cfTypes.StackStatus(instance.Status.StackStatus))
+	})
+}
+
+// resyncLoop periodically refreshes the status of all Stacks, so that they converge even if an
+// event was missed.
+func (f *StackFollower) resyncLoop(ctx context.Context) {
+	ticker := time.NewTicker(f.ResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.Log.Info("Resyncing all stacks")
+			err := f.followStacks(ctx, func(*cloudformationv1alpha1.Stack) bool {
+				return true
+			})
+			if err != nil {
+				f.Log.Error(err, "Failed to resync stacks")
+			}
+		}
+	}
+}
+
+// followStacks follows all Stacks with a CloudFormation stack matching the filter. Stacks in a terminal
+// state get their status refreshed once.
+func (f *StackFollower) followStacks(ctx context.Context, filter func(*cloudformationv1alpha1.Stack) bool) error {
+	stacks := &cloudformationv1alpha1.StackList{}
+	if err := f.List(ctx, stacks); err != nil {
+		return err
+	}
+
+	for i := range stacks.Items {
+		instance := &stacks.Items[i]
+		if instance.Status.StackID == "" && instance.Status.StackStatus == "" {
+			continue
+		}
+		managed, err := f.Namespaces.Matches(ctx, instance.Namespace)
 		if err != nil {
-			f.Log.Error(err, "Failed to get CloudFormation stack")
 			return err
 		}
+		if (managed || finalizing(instance)) && f.Stacks.Matches(instance) && filter(instance) {
+			f.Follow(instance)
+		}
 	}
+	return nil
+}
 
-	outputs := map[string]string{}
-	if cfs.Outputs != nil && len(cfs.Outputs) > 0 {
-		for _, output := range cfs.Outputs {
-			outputs[*output.OutputKey] = *output.OutputValue
+func (f *StackFollower) processNextItem(ctx context.Context) bool {
+	item, shutdown := f.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer f.queue.Done(item)
+
+	uid := item.(types.UID)
+	after, err := f.processStack(ctx, uid)
+	switch {
+	case err != nil:
+		f.Log.Error(err, "Failed to process stack, retrying", "UID", uid)
+		f.queue.AddRateLimited(uid)
+	case after > 0:
+		f.queue.Forget(uid)
+		f.queue.AddAfter(uid, after)
+	}
+	return true
+}
+
+// processStack refreshes the status of a followed Stack from a fresh copy of the object and
+// returns how long to wait before polling it again, 0 if it doesn't need to be polled again.
+func (f *StackFollower) processStack(ctx context.Context, uid types.UID) (after time.Duration, err error) {
+	ctx, span := tracer().Start(ctx, "processStack", trace.WithAttributes(stackUIDKey.String(string(uid))))
+	defer func() { endSpan(span, err) }()
+
+	value, followed := f.following.Load(uid)
+	if !followed {
+		return 0, nil
+	}
+	key := value.(types.NamespacedName)
+	f.refollow.Delete(uid)
+
+	instance := &cloudformationv1alpha1.Stack{}
+	if err := f.Get(ctx, key, instance); err != nil {
+		if errors.IsNotFound(err) {
+			f.stopFollowing(uid)
+			return 0, nil
 		}
+		return 0, err
+	}
+	if instance.UID != uid {
+		// The Stack object was replaced by a new one with the same name.
+		f.stopFollowing(uid)
+		return 0, nil
+	}
+	managed, err := f.Namespaces.Matches(ctx, instance.Namespace)
+	if err != nil {
+		return 0, err
 	}
+	if (!managed && !finalizing(instance)) || !f.Stacks.Matches(instance) {
+		// The namespace was deselected or the Stack handed over to another operator instance. Stacks which
+		// are being deleted are followed until they're gone, so that their finalizer is removed.
+		f.stopFollowing(uid)
+		return 0, nil
+	}
+	// Holding the lock until following stopped makes sure that a new operation is either seen by this poll
+	// or asks to be followed after following stopped. The stack is polled later instead of waiting for the
+	// lock, so that other stacks are still polled while the reconciler calls CloudFormation.
+	unlock, locked := f.StackLocks.TryLock(instance.GetStackName())
+	if !locked {
+		f.Log.V(1).Info("Stack is being reconciled, polling it later", "UID", uid)
+		return f.initialPollInterval(), nil
+	}
+	defer unlock()
+	span.SetAttributes(stackAttributes(instance)...)
 
-	// Checking the status
-	if string(cfs.StackStatus) != instance.Status.StackStatus {
-		update = true
-		instance.Status.StackStatus = string(cfs.StackStatus)
-		instance.Status.CreatedTime = metav1.NewTime(*cfs.CreationTime)
-		if cfs.LastUpdatedTime != nil {
-			instance.Status.UpdatedTime = metav1.NewTime(*cfs.LastUpdatedTime)
+	cfs, err := f.CloudFormationHelper.GetStack(ctx, instance)
+	if err != nil {
+		if err == ErrStackNotFound {
+			f.Log.Error(err, "Stack Not Found", "UID", uid, "Stack ID", instance.Status.StackID)
+			f.stopFollowing(uid)
+			return 0, nil
 		}
+		return 0, err
 	}
 
-	// Checking stack ID and outputs for changes.
-	stackID := *cfs.StackId
-	if stackID != instance.Status.StackID || !reflect.DeepEqual(outputs, instance.Status.Outputs) {
-		update = true
-		instance.Status.StackID = stackID
-		if len(outputs) > 0 {
-			instance.Status.Outputs = outputs
+	span.SetAttributes(stackIDKey.String(aws.ToString(cfs.StackId)), stackStatusKey.String(string(cfs.StackStatus)))
+
+	if err := f.recordStackStatus(ctx, instance, cfs); err != nil {
+		return 0, err
+	}
+
+	// Stop following on the last pass, so the reconciler can catch it on the next loop.
+	if f.CloudFormationHelper.StackInTerminalState(cfs.StackStatus) {
+		if _, again := f.refollow.Load(uid); again {
+			f.refollow.Delete(uid)
+			return f.initialPollInterval(), nil
 		}
+		f.stopFollowing(uid)
+		return 0, nil
 	}
 
-	// Recording all stack resources
-	resources, err := f.CloudFormationHelper.GetStackResources(ctx, instance.Status.StackID)
+	after = f.pollInterval(instance, cfs.StackStatus)
+	stackNextPoll.WithLabelValues(instance.Namespace, instance.Name).Set(float64(time.Now().Add(after).Unix()))
+	f.Log.V(1).Info("Polling stack again", "UID", uid, "after", after)
+	return after, nil
+}
+
+// RecordFinishedOperation records the stack in the Stack's status if it finished an operation since it was last
+// polled. The reconciler calls it before starting another operation, which may hide the finished one from polls.
+func (f *StackFollower) RecordFinishedOperation(ctx context.Context, instance *cloudformationv1alpha1.Stack, stack *cfTypes.Stack) error {
+	if !stackStatusChanged(&instance.Status, stack) {
+		return nil
+	}
+	return f.recordStackStatus(ctx, instance, stack)
+}
+
+// recordStackStatus updates the Stack's status and records the operation the stack finished, if any.
+func (f *StackFollower) recordStackStatus(ctx context.Context, instance *cloudformationv1alpha1.Stack, stack *cfTypes.Stack) error {
+	finished, err := f.updateStackStatus(ctx, instance, stack)
 	if err != nil {
-		f.Log.Error(err, "Failed to get Stack Resources")
 		return err
 	}
-	if !reflect.DeepEqual(resources, instance.Status.Resources) {
-		update = true
-		instance.Status.Resources = resources
-	}
-
-	if update {
-		err = f.Status().Update(ctx, instance)
+	if finished {
+		finishedAt, err := f.CloudFormationHelper.GetStackFinishedTime(ctx, stack)
 		if err != nil {
-			f.Log.Error(err, "Failed to update Stack Status")
-			if errors.IsNotFound(err) {
-				// Request object not found, could have been deleted after reconcile request.
-				// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
-				// Return and don't requeue
-				// return reconcile.Result{}, nil
-				return nil
-			}
-			// Error reading the object - requeue the request.
-			// return reconcile.Result{}, err
-			return err
+			f.Log.Error(err, "Failed to get when the stack operation finished", "UID", instance.UID)
 		}
+		recordStackOperation(instance, stack, finishedAt)
+		f.recordTerminalState(instance, stack)
 	}
-
 	return nil
 }
 
-func (f *StackFollower) processStack(key interface{}, value interface{}) bool {
+// recordTerminalState emits an Event about the stack reaching a terminal state, which is a Warning if the
+// operation failed or was rolled back.
+func (f *StackFollower) recordTerminalState(instance *cloudformationv1alpha1.Stack, stack *cfTypes.Stack) {
+	eventType := corev1.EventTypeNormal
+	if strings.Contains(string(stack.StackStatus), "FAILED") || strings.Contains(string(stack.StackStatus), "ROLLBACK") {
+		eventType = corev1.EventTypeWarning
+	}
 
-	stackId := key.(string)
-	stack := value.(*cloudformationv1alpha1.Stack)
+	message := fmt.Sprintf("Stack %s is in %s", aws.ToString(stack.StackName), stack.StackStatus)
+	if stack.StackStatusReason != nil {
+		message = fmt.Sprintf("%s: %s", message, *stack.StackStatusReason)
+	}
+	f.Recorder.Event(instance, eventType, statusReason(stack.StackStatus), message)
+}
 
-	cfs, err := f.CloudFormationHelper.GetStack(context.TODO(), stack)
-	if err != nil {
-		if err == ErrStackNotFound {
-			f.Log.Error(err, "Stack Not Found", "UID", stack.UID, "Stack ID", stackId)
-			f.stopFollowing(stackId)
-		} else {
-			f.Log.Error(err, "Error retrieving stack for processing", "UID", stack.UID, "Stack ID", stackId)
+// statusReason converts a stack status like UPDATE_ROLLBACK_COMPLETE to the reason UpdateRollbackComplete.
+func statusReason(status cfTypes.StackStatus) string {
+	var reason strings.Builder
+	for _, word := range strings.Split(strings.ToLower(string(status)), "_") {
+		if word != "" {
+			reason.WriteString(strings.ToUpper(word[:1]) + word[1:])
 		}
-	} else {
-		// Have to remove the lock on the last pass, so the reconciler can catch it on the next loop.
-		if f.CloudFormationHelper.StackInTerminalState(cfs.StackStatus) {
-			f.stopFollowing(stackId)
+	}
+	return reason.String()
+}
+
+// pollInterval returns how long to wait before polling the given stack again. A stack is polled every
+// PollInterval after its status changed and less often the longer it stays in the same status, up to
+// MaxPollInterval. The PollIntervalAnnotation replaces this with a fixed interval.
+func (f *StackFollower) pollInterval(instance *cloudformationv1alpha1.Stack, status cfTypes.StackStatus) time.Duration {
+	if value, ok := instance.Annotations[cloudformationv1alpha1.PollIntervalAnnotation]; ok {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval > 0 {
+			return wait.Jitter(interval, f.PollJitter)
 		}
-		err = f.UpdateStackStatus(context.TODO(), stack, cfs)
+		f.Log.Info("Ignoring invalid poll interval", "UID", instance.UID, "value", value)
+	}
+
+	value, _ := f.polls.LoadOrStore(instance.UID, &pollState{})
+	state := value.(*pollState)
+	switch {
+	case state.stackStatus != status:
+		state.interval = f.initialPollInterval()
+		state.stackStatus = status
+	case state.interval < f.MaxPollInterval:
+		state.interval *= pollBackoffFactor
+	}
+	if f.MaxPollInterval > 0 && state.interval > f.MaxPollInterval {
+		state.interval = f.MaxPollInterval
+	}
+
+	return wait.Jitter(state.interval, f.PollJitter)
+}
+
+// stackStatusChanged returns whether the status or the last operation of the stack differ from the recorded ones.
+func stackStatusChanged(status *cloudformationv1alpha1.StackStatus, stack *cfTypes.Stack) bool {
+	recorded := status.UpdatedTime.Time
+	if recorded.IsZero() {
+		recorded = status.CreatedTime.Time
+	}
+	// The status is serialized with a precision of seconds.
+	started := stackLastUpdated(stack).Truncate(time.Second)
+	return string(stack.StackStatus) != status.StackStatus || !started.Equal(recorded)
+}
+
+// initialPollInterval returns the PollInterval, falling back to its default if it isn't positive, as a stack
+// which isn't polled again would never be followed to its terminal state.
+func (f *StackFollower) initialPollInterval() time.Duration {
+	if f.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return f.PollInterval
+}
+
+// UpdateStackStatus records the current state of the CloudFormation stack in the Stack's status.
+// Allow passing a current/recent fetch of the stack object to the method (optionally)
+func (f *StackFollower) UpdateStackStatus(ctx context.Context, instance *cloudformationv1alpha1.Stack, stack ...*cfTypes.Stack) error {
+	var cfs *cfTypes.Stack
+
+	if len(stack) > 0 {
+		cfs = stack[0]
+	}
+	if cfs == nil {
+		var err error
+		cfs, err = f.CloudFormationHelper.GetStack(ctx, instance)
 		if err != nil {
-			f.Log.Error(err, "Failed to update stack status", "UID", stack.UID, "Stack ID", stackId)
-			// On error put it back to make sure we save it next time.
-			f.startFollowing(stack)
+			f.Log.Error(err, "Failed to get CloudFormation stack")
+			return err
 		}
 	}
 
-	return true
+	_, err := f.updateStackStatus(ctx, instance, cfs)
+	return err
 }
 
-func (f *StackFollower) Worker() {
+// updateStackStatus records the given state of the CloudFormation stack in the Stack's status and returns
+// whether it finished an operation which wasn't recorded yet. Operations are told apart by their type and
+// start rather than by the previous status, as a fast operation may start and finish between two polls.
+func (f *StackFollower) updateStackStatus(ctx context.Context, instance *cloudformationv1alpha1.Stack, cfs *cfTypes.Stack) (finished bool, err error) {
+	outputs := map[string]string{}
+	if cfs.Outputs != nil && len(cfs.Outputs) > 0 {
+		for _, output := range cfs.Outputs {
+			outputs[*output.OutputKey] = *output.OutputValue
+		}
+	}
 
-	for {
-		time.Sleep(time.Second * 5)
-		f.mapPollingList.Range(f.processStack)
+	// Recording all stack resources
+	resources, err := f.CloudFormationHelper.GetStackResources(ctx, cfs)
+	if err != nil {
+		f.Log.Error(err, "Failed to get Stack Resources")
+		return false, err
+	}
+
+	err = patchStackStatus(ctx, f.Client, f.APIReader, followerFieldManager, instance, func(status *cloudformationv1alpha1.StackStatus) {
+		finished = false
+
+		// A poll of an outdated Stack, e.g. of the failed stack it was recreated in place of, mustn't overwrite
+		// the status of the stack which replaced it.
+		if status.StackID != "" && status.StackID != aws.ToString(cfs.StackId) {
+			return
+		}
+
+		// Checking the status
+		if stackStatusChanged(status, cfs) {
+			finished = f.CloudFormationHelper.StackInTerminalState(cfs.StackStatus)
+			status.StackStatus = string(cfs.StackStatus)
+			status.CreatedTime = metav1.NewTime(*cfs.CreationTime)
+			status.UpdatedTime = metav1.Time{}
+			if cfs.LastUpdatedTime != nil {
+				status.UpdatedTime = metav1.NewTime(*cfs.LastUpdatedTime)
+			}
+		}
+
+		status.StackID = *cfs.StackId
+		status.Outputs = nil
+		if len(outputs) > 0 {
+			status.Outputs = outputs
+		}
+		status.Resources = resources
+	})
+	if err != nil {
+		f.Log.Error(err, "Failed to update Stack Status")
+		if errors.IsNotFound(err) {
+			// Stack object not found, could have been deleted in the meantime.
+			return false, nil
+		}
+		return false, err
 	}
 
+	return finished, nil
 }
@@ -0,0 +1,165 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+var _ = Describe("Stack follower", func() {
+	ctx := context.Background()
+
+	var follower *StackFollower
+	var inProgress, complete *cloudformationv1alpha1.Stack
+
+	// newStackWithStatus creates a Stack in a namespace the operator under test ignores, so that only
+	// the follower of the test looks at it.
+	newStackWithStatus := func(name string, status string) *cloudformationv1alpha1.Stack {
+		instance := &cloudformationv1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "unfollowed", Name: name},
+			Spec:       cloudformationv1alpha1.StackSpec{Template: bucketTemplate},
+		}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		if status == "" {
+			return instance
+		}
+		instance.Status.StackID = "arn:aws:cloudformation:us-east-1:123456789012:stack/" + name + "/1"
+		instance.Status.StackStatus = status
+		Expect(k8sClient.Status().Update(ctx, instance)).To(Succeed())
+		return instance
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "unfollowed",
+			Labels: map[string]string{ignoredNamespaceLabel: "true"},
+		}}
+		if err := k8sClient.Create(ctx, ns); !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		follower = NewStackFollower(k8sClient, ctrl.Log.WithName("workers").WithName("Test"), &CloudFormationHelper{CloudFormation: fakeCloudFormation})
+		follower.Namespaces = &NamespaceFilter{Namespaces: []string{"unfollowed"}}
+		follower.Stacks = &StackFilter{}
+		inProgress = newStackWithStatus("in-progress", "UPDATE_IN_PROGRESS")
+		complete = newStackWithStatus("complete", "UPDATE_COMPLETE")
+		newStackWithStatus("never-created", "")
+	})
+
+	AfterEach(func() {
+		follower.queue.ShutDown()
+		Expect(k8sClient.DeleteAllOf(ctx, &cloudformationv1alpha1.Stack{}, client.InNamespace("unfollowed"))).To(Succeed())
+	})
+
+	It("resumes following Stacks whose stack was in progress", func() {
+		Expect(follower.resumeFollowing(ctx)).To(Succeed())
+
+		Expect(follower.BeingFollowed(inProgress.UID)).To(BeTrue())
+		Expect(follower.BeingFollowed(complete.UID)).To(BeFalse())
+		Expect(follower.queue.Len()).To(Equal(1))
+	})
+
+	It("polls stacks which are being reconciled later instead of waiting for them", func() {
+		follower.StackLocks = NewStackLocks()
+		defer follower.StackLocks.Lock(inProgress.GetStackName())()
+		follower.Follow(inProgress)
+
+		after, err := follower.processStack(ctx, inProgress.UID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(after).To(Equal(follower.PollInterval))
+		Expect(follower.BeingFollowed(inProgress.UID)).To(BeTrue())
+	})
+
+	It("periodically follows all Stacks with a stack", func() {
+		follower.ResyncInterval = 50 * time.Millisecond
+		resyncCtx, stopResync := context.WithCancel(ctx)
+		defer stopResync()
+		go follower.resyncLoop(resyncCtx)
+
+		Eventually(func() bool {
+			return follower.BeingFollowed(inProgress.UID) && follower.BeingFollowed(complete.UID)
+		}, timeout, interval).Should(BeTrue())
+		Expect(follower.queue.Len()).To(Equal(2))
+	})
+})
+
+var _ = Describe("Stack follower poll interval", func() {
+	var follower *StackFollower
+	var instance *cloudformationv1alpha1.Stack
+
+	// Expect the interval to be within the jitter of the expected one.
+	expectInterval := func(interval, expected time.Duration) {
+		ExpectWithOffset(1, interval).To(BeNumerically(">=", expected))
+		ExpectWithOffset(1, interval).To(BeNumerically("<=", time.Duration(float64(expected)*1.1)))
+	}
+
+	BeforeEach(func() {
+		follower = NewStackFollower(k8sClient, ctrl.Log.WithName("workers").WithName("Test"), &CloudFormationHelper{})
+		follower.PollInterval = time.Second
+		follower.MaxPollInterval = 5 * time.Second
+		follower.PollJitter = 0.1
+		instance = &cloudformationv1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{UID: "poll-interval"}}
+	})
+
+	It("backs off while the status doesn't change", func() {
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), time.Second)
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), 2*time.Second)
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), 4*time.Second)
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), 5*time.Second)
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), 5*time.Second)
+
+		// A new status starts over.
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusUpdateInProgress), time.Second)
+	})
+
+	It("polls at the interval of the annotation", func() {
+		instance.Annotations = map[string]string{cloudformationv1alpha1.PollIntervalAnnotation: "30s"}
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), 30*time.Second)
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), 30*time.Second)
+
+		instance.Annotations[cloudformationv1alpha1.PollIntervalAnnotation] = "0s"
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), time.Second)
+	})
+
+	It("falls back to the default interval", func() {
+		follower.PollInterval = 0
+		follower.MaxPollInterval = 0
+		expectInterval(follower.pollInterval(instance, cfTypes.StackStatusCreateInProgress), defaultPollInterval)
+		expectInterval(follower.initialPollInterval(), defaultPollInterval)
+	})
+})
@@ -0,0 +1,99 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"sync"
+)
+
+// StackLocks serialise the work on each CloudFormation stack, so that concurrent reconciles and the
+// StackFollower never act on the same stack at once. A nil StackLocks doesn't lock anything.
+type StackLocks struct {
+	mu    sync.Mutex
+	locks map[string]*stackLock
+}
+
+// stackLock is the lock of one stack, it's dropped once nobody holds or waits for it.
+type stackLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewStackLocks creates StackLocks to be shared by the StackReconciler and the StackFollower.
+func NewStackLocks() *StackLocks {
+	return &StackLocks{locks: map[string]*stackLock{}}
+}
+
+// Lock blocks until the stack with the given name is free and returns the func to free it again.
+func (l *StackLocks) Lock(stackName string) (unlock func()) {
+	if l == nil {
+		return func() {}
+	}
+
+	l.mu.Lock()
+	lock, ok := l.locks[stackName]
+	if !ok {
+		lock = &stackLock{}
+		l.locks[stackName] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return l.unlock(stackName, lock)
+}
+
+// TryLock locks the stack with the given name if it's free and returns the func to free it again, or false
+// without waiting if it isn't free.
+func (l *StackLocks) TryLock(stackName string) (unlock func(), ok bool) {
+	if l == nil {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A stack has a lock only while somebody holds or waits for it.
+	if _, held := l.locks[stackName]; held {
+		return nil, false
+	}
+	lock := &stackLock{refs: 1}
+	lock.Lock()
+	l.locks[stackName] = lock
+	return l.unlock(stackName, lock), true
+}
+
+func (l *StackLocks) unlock(stackName string, lock *stackLock) func() {
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, stackName)
+		}
+	}
+}
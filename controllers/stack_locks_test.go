@@ -0,0 +1,71 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stack locks", func() {
+	It("only lock stacks which are free", func() {
+		locks := NewStackLocks()
+
+		unlock, ok := locks.TryLock("first")
+		Expect(ok).To(BeTrue())
+		_, ok = locks.TryLock("first")
+		Expect(ok).To(BeFalse())
+		unlockSecond, ok := locks.TryLock("second")
+		Expect(ok).To(BeTrue())
+		unlockSecond()
+
+		locked := make(chan func())
+		go func() {
+			locked <- locks.Lock("first")
+		}()
+		Consistently(locked, 100*time.Millisecond).ShouldNot(Receive())
+		unlock()
+		Eventually(locked).Should(Receive(&unlock))
+
+		_, ok = locks.TryLock("first")
+		Expect(ok).To(BeFalse())
+		unlock()
+		unlock, ok = locks.TryLock("first")
+		Expect(ok).To(BeTrue())
+		unlock()
+		Expect(locks.locks).To(BeEmpty())
+	})
+
+	It("don't lock anything if nil", func() {
+		var locks *StackLocks
+		unlock, ok := locks.TryLock("first")
+		Expect(ok).To(BeTrue())
+		defer unlock()
+		defer locks.Lock("first")()
+	})
+})
@@ -0,0 +1,126 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+var stacksDesc = prometheus.NewDesc(
+	"cloudformation_operator_stacks",
+	"Number of Stacks by namespace and CloudFormation stack status.",
+	[]string{"namespace", "status"}, nil,
+)
+
+// stackCollector counts the Stacks by namespace and status whenever metrics are scraped.
+type stackCollector struct {
+	client client.Reader
+}
+
+// NewStackCollector creates a prometheus.Collector reporting the number of Stacks by namespace and status.
+// It should read from the manager's cache.
+func NewStackCollector(c client.Reader) prometheus.Collector {
+	return &stackCollector{client: c}
+}
+
+// Describe implements prometheus.Collector.
+func (c *stackCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stacksDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *stackCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stacks := &cloudformationv1alpha1.StackList{}
+	if err := c.client.List(ctx, stacks); err != nil {
+		ch <- prometheus.NewInvalidMetric(stacksDesc, err)
+		return
+	}
+
+	counts := map[[2]string]int{}
+	for _, stack := range stacks.Items {
+		counts[[2]string{stack.Namespace, stack.Status.StackStatus}]++
+	}
+	for labels, count := range counts {
+		ch <- prometheus.MustNewConstMetric(stacksDesc, prometheus.GaugeValue, float64(count), labels[0], labels[1])
+	}
+}
+
+// recordStackOperation records the duration and outcome of the operation that brought a stack into its
+// terminal state at the given time. The duration isn't recorded if that time is unknown, as it would
+// otherwise depend on when the follower noticed.
+func recordStackOperation(instance *cloudformationv1alpha1.Stack, stack *cfTypes.Stack, finished time.Time) {
+	status := string(stack.StackStatus)
+	operation := stackOperation(stack.StackStatus)
+
+	result := "succeeded"
+	switch {
+	case strings.HasSuffix(status, "_FAILED"):
+		result = "failed"
+		stackFailures.WithLabelValues(instance.Namespace, operation).Inc()
+	case strings.Contains(status, "ROLLBACK"):
+		result = "rolled_back"
+		stackRollbacks.WithLabelValues(instance.Namespace, operation).Inc()
+	}
+
+	var started *time.Time
+	switch operation {
+	case "create":
+		started = stack.CreationTime
+	case "update":
+		started = stack.LastUpdatedTime
+	case "delete":
+		started = stack.DeletionTime
+	}
+	if started != nil && !finished.IsZero() {
+		stackOperationDuration.WithLabelValues(operation, result).Observe(finished.Sub(*started).Seconds())
+	}
+}
+
+// stackOperation returns the operation a stack status belongs to.
+func stackOperation(status cfTypes.StackStatus) string {
+	switch s := string(status); {
+	case strings.HasPrefix(s, "CREATE_"), strings.HasPrefix(s, "ROLLBACK_"):
+		return "create"
+	case strings.HasPrefix(s, "UPDATE_"):
+		return "update"
+	case strings.HasPrefix(s, "DELETE_"):
+		return "delete"
+	case strings.HasPrefix(s, "IMPORT_"):
+		return "import"
+	}
+	return "other"
+}
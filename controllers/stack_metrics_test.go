@@ -0,0 +1,119 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+var _ = Describe("Stack metrics", func() {
+	It("counts the Stacks by namespace and status", func() {
+		scheme := runtime.NewScheme()
+		Expect(cloudformationv1alpha1.AddToScheme(scheme)).To(Succeed())
+		stack := func(namespace, name, status string) runtime.Object {
+			return &cloudformationv1alpha1.Stack{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Status:     cloudformationv1alpha1.StackStatus{StackStatus: status},
+			}
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(
+			stack("team-a", "first", "CREATE_COMPLETE"),
+			stack("team-a", "second", "CREATE_COMPLETE"),
+			stack("team-a", "third", "UPDATE_IN_PROGRESS"),
+			stack("team-b", "first", "CREATE_COMPLETE"),
+		).Build()
+
+		Expect(testutil.CollectAndCompare(NewStackCollector(c), strings.NewReader(`
+# HELP cloudformation_operator_stacks Number of Stacks by namespace and CloudFormation stack status.
+# TYPE cloudformation_operator_stacks gauge
+cloudformation_operator_stacks{namespace="team-a",status="CREATE_COMPLETE"} 2
+cloudformation_operator_stacks{namespace="team-a",status="UPDATE_IN_PROGRESS"} 1
+cloudformation_operator_stacks{namespace="team-b",status="CREATE_COMPLETE"} 1
+`))).To(Succeed())
+	})
+
+	It("records the duration and outcome of finished operations", func() {
+		instance := &cloudformationv1alpha1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "metrics", Name: "operation"}}
+		started := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		// The operations are ones the fake never ends in, so that the Stacks of other tests don't add to them.
+		deleteFailed := &cfTypes.Stack{StackStatus: cfTypes.StackStatusDeleteFailed, DeletionTime: aws.Time(started)}
+		recordStackOperation(instance, deleteFailed, started.Add(90*time.Second))
+		// Without the time the operation finished its duration is unknown.
+		recordStackOperation(instance, deleteFailed, time.Time{})
+		recordStackOperation(instance, &cfTypes.Stack{
+			StackStatus:     cfTypes.StackStatusUpdateRollbackComplete,
+			CreationTime:    aws.Time(started.Add(-time.Hour)),
+			LastUpdatedTime: aws.Time(started),
+		}, started.Add(time.Minute))
+
+		Expect(testutil.ToFloat64(stackFailures.WithLabelValues("metrics", "delete"))).To(Equal(float64(2)))
+		Expect(testutil.ToFloat64(stackRollbacks.WithLabelValues("metrics", "update"))).To(Equal(float64(1)))
+		Expect(testutil.CollectAndCompare(stackOperationDuration.WithLabelValues("delete", "failed").(prometheus.Histogram),
+			strings.NewReader(`
+# HELP cloudformation_operator_stack_operation_duration_seconds Time from submitting a stack operation until it finished, by operation and result.
+# TYPE cloudformation_operator_stack_operation_duration_seconds histogram
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="15"} 0
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="30"} 0
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="60"} 0
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="120"} 1
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="240"} 1
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="480"} 1
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="960"} 1
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="1920"} 1
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="3840"} 1
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="7680"} 1
+cloudformation_operator_stack_operation_duration_seconds_bucket{operation="delete",result="failed",le="+Inf"} 1
+cloudformation_operator_stack_operation_duration_seconds_sum{operation="delete",result="failed"} 90
+cloudformation_operator_stack_operation_duration_seconds_count{operation="delete",result="failed"} 1
+`))).To(Succeed())
+	})
+
+	table.DescribeTable("tells the operation of a stack status",
+		func(status cfTypes.StackStatus, operation string) {
+			Expect(stackOperation(status)).To(Equal(operation))
+		},
+		table.Entry("create", cfTypes.StackStatusCreateComplete, "create"),
+		table.Entry("rolled back create", cfTypes.StackStatusRollbackComplete, "create"),
+		table.Entry("update", cfTypes.StackStatusUpdateRollbackComplete, "update"),
+		table.Entry("delete", cfTypes.StackStatusDeleteFailed, "delete"),
+		table.Entry("import", cfTypes.StackStatusImportComplete, "import"),
+		table.Entry("review", cfTypes.StackStatusReviewInProgress, "other"),
+	)
+})
@@ -0,0 +1,112 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+// recoverStack continues the rollback of a stack in UPDATE_ROLLBACK_FAILED if the Stack's recovery policy allows it.
+// Once the stack is back in UPDATE_ROLLBACK_COMPLETE the next reconcile retries the desired update.
+func (r *StackReconciler) recoverStack(loop *StackLoop) error {
+	recovery := loop.instance.Spec.Recovery
+	if recovery == nil || !recovery.ContinueUpdateRollback {
+		r.Log.WithValues("stack", loop.instance.Name).Info("stack needs recovery but it isn't enabled")
+		return r.setCondition(loop, cloudformationv1alpha1.ConditionRecovering, metav1.ConditionFalse,
+			cloudformationv1alpha1.ReasonRecoveryDisabled,
+			fmt.Sprintf("stack is in %s, enable spec.recovery.continueUpdateRollback to recover it", cfTypes.StackStatusUpdateRollbackFailed))
+	}
+
+	r.Log.WithValues("stack", loop.instance.Name).Info("continuing update rollback")
+
+	if loop.settings.DryRun {
+		r.Log.WithValues("stack", loop.instance.Name).Info("skipping continuing update rollback")
+		return nil
+	}
+
+	hasOwnership, err := r.hasOwnership(loop)
+	if err != nil {
+		return err
+	}
+
+	if !hasOwnership {
+		r.Log.WithValues("stack", loop.instance.Name).Info("no ownership")
+		r.recordNotOwned(loop)
+		return nil
+	}
+
+	input := &cloudformation.ContinueUpdateRollbackInput{
+		StackName:       aws.String(loop.instance.GetStackName()),
+		ResourcesToSkip: recovery.ResourcesToSkip,
+	}
+
+	if _, err := r.CloudFormation.ContinueUpdateRollback(loop.ctx, input); err != nil {
+		r.Recorder.Eventf(loop.instance, corev1.EventTypeWarning, cloudformationv1alpha1.ReasonRecoveryFailed,
+			"Failed to continue update rollback: %v", err)
+		if statusErr := r.setCondition(loop, cloudformationv1alpha1.ConditionRecovering, metav1.ConditionFalse,
+			cloudformationv1alpha1.ReasonRecoveryFailed, err.Error()); statusErr != nil {
+			r.Log.WithValues("stack", loop.instance.Name).Error(statusErr, "error recording recovery failure")
+		}
+		return err
+	}
+	r.CloudFormationHelper.InvalidateStack(loop.instance)
+
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonContinueUpdateRollback,
+		"Continuing update rollback, skipping resources %v", recovery.ResourcesToSkip)
+	if err := r.setCondition(loop, cloudformationv1alpha1.ConditionRecovering, metav1.ConditionTrue,
+		cloudformationv1alpha1.ReasonContinueUpdateRollback, "continuing rollback of the failed update"); err != nil {
+		return err
+	}
+
+	r.StackFollower.Follow(loop.instance)
+	return nil
+}
+
+// finishRecovery marks a previous recovery as done once the stack left UPDATE_ROLLBACK_FAILED.
+func (r *StackReconciler) finishRecovery(loop *StackLoop) error {
+	condition := meta.FindStatusCondition(loop.instance.Status.Conditions, cloudformationv1alpha1.ConditionRecovering)
+	if condition == nil || condition.Reason == cloudformationv1alpha1.ReasonRecovered {
+		return nil
+	}
+
+	// Only a rollback the operator continued is reported as recovered, a stack may also have been recovered by hand.
+	if condition.Reason == cloudformationv1alpha1.ReasonContinueUpdateRollback {
+		r.Log.WithValues("stack", loop.instance.Name).Info("stack recovered", "status", loop.stack.StackStatus)
+		r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonRecovered,
+			"Stack recovered from %s, retrying the update", cfTypes.StackStatusUpdateRollbackFailed)
+	}
+	return r.setCondition(loop, cloudformationv1alpha1.ConditionRecovering, metav1.ConditionFalse,
+		cloudformationv1alpha1.ReasonRecovered, fmt.Sprintf("stack is in %s", loop.stack.StackStatus))
+}
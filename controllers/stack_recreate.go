@@ -0,0 +1,205 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+const (
+	defaultRecreateDelay = 30 * time.Second
+	recreateMaxDelay     = 15 * time.Minute
+)
+
+// stackCreateFailed returns whether a stack failed on its first creation and can only be deleted.
+func stackCreateFailed(status cfTypes.StackStatus) bool {
+	return status == cfTypes.StackStatusRollbackComplete || status == cfTypes.StackStatusCreateFailed
+}
+
+// recreateBackoff returns how long to wait before recreating a stack for the given attempt, starting at the
+// given delay.
+func recreateBackoff(delay time.Duration, attempt int32) time.Duration {
+	if delay <= 0 {
+		delay = defaultRecreateDelay
+	}
+	for i := int32(0); i < attempt && delay < recreateMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > recreateMaxDelay {
+		delay = recreateMaxDelay
+	}
+	return delay
+}
+
+// recreateStack deletes a stack whose creation failed after backing off, so that it can be created again.
+func (r *StackReconciler) recreateStack(loop *StackLoop) (ctrl.Result, error) {
+	hasOwnership, err := r.hasOwnership(loop)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !hasOwnership {
+		r.Log.WithValues("stack", loop.instance.Name).Info("no ownership")
+		r.recordNotOwned(loop)
+		return ctrl.Result{}, nil
+	}
+
+	attempts := loop.instance.Status.RecreateAttempts
+	// A requested reconcile recreates the stack right away, even once all attempts were made.
+	exhausted := attempts >= loop.instance.GetRecreateAttempts() && !loop.forced
+
+	// The events of the stack are only paged through to find out when it failed while it's going to be
+	// recreated, or once to record why it failed.
+	reason := loop.instance.Status.FailureReason
+	var failedAt time.Time
+	if !exhausted || reason == "" {
+		if reason, failedAt, err = r.CloudFormationHelper.GetStackFailure(loop.ctx, loop.stack); err != nil {
+			return ctrl.Result{}, err
+		}
+		if attempts == 0 {
+			if err := r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+				status.FailureReason = reason
+			}); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	if exhausted {
+		condition := meta.FindStatusCondition(loop.instance.Status.Conditions, cloudformationv1alpha1.ConditionRecreating)
+		if condition == nil || condition.Reason != cloudformationv1alpha1.ReasonRecreateAttemptsExhausted {
+			r.Recorder.Eventf(loop.instance, corev1.EventTypeWarning, cloudformationv1alpha1.ReasonRecreateAttemptsExhausted,
+				"Not recreating stack after %d attempts: %s", attempts, reason)
+		}
+		r.Log.WithValues("stack", loop.instance.Name).Info("not recreating failed stack", "attempts", attempts)
+		return ctrl.Result{}, r.setCondition(loop, cloudformationv1alpha1.ConditionRecreating, metav1.ConditionFalse,
+			cloudformationv1alpha1.ReasonRecreateAttemptsExhausted,
+			fmt.Sprintf("stack is in %s after %d attempts to recreate it", loop.stack.StackStatus, attempts))
+	}
+
+	recreateAt := failedAt.Add(recreateBackoff(r.RecreateDelay, attempts))
+	if wait := time.Until(recreateAt); wait > 0 && !loop.forced {
+		r.Log.WithValues("stack", loop.instance.Name).Info("waiting to recreate failed stack", "after", wait)
+		return ctrl.Result{RequeueAfter: wait}, r.setCondition(loop, cloudformationv1alpha1.ConditionRecreating, metav1.ConditionTrue,
+			cloudformationv1alpha1.ReasonWaitingToRecreate,
+			fmt.Sprintf("stack is in %s, recreating it after %s", loop.stack.StackStatus, recreateAt.Format(time.RFC3339)))
+	}
+
+	r.Log.WithValues("stack", loop.instance.Name).Info("deleting failed stack", "attempt", attempts+1)
+
+	if loop.settings.DryRun {
+		r.Log.WithValues("stack", loop.instance.Name).Info("skipping failed stack deletion")
+		return ctrl.Result{}, nil
+	}
+
+	input := &cloudformation.DeleteStackInput{
+		StackName: loop.stack.StackId,
+	}
+
+	if _, err := r.CloudFormation.DeleteStack(loop.ctx, input); err != nil {
+		r.recordFailure(loop, cloudformationv1alpha1.ReasonDeleteStackFailed, err)
+		return ctrl.Result{}, err
+	}
+	r.CloudFormationHelper.InvalidateStack(loop.instance)
+
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonDeletingFailedStack,
+		"Deleting stack in %s to create it again", loop.stack.StackStatus)
+	if err := r.setCondition(loop, cloudformationv1alpha1.ConditionRecreating, metav1.ConditionTrue,
+		cloudformationv1alpha1.ReasonDeletingFailedStack, fmt.Sprintf("deleting stack in %s", loop.stack.StackStatus)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.StackFollower.Follow(loop.instance)
+	return ctrl.Result{}, nil
+}
+
+// stackRecreatePending returns whether the stack is the deleted remainder of a failed creation.
+func stackRecreatePending(loop *StackLoop) bool {
+	condition := meta.FindStatusCondition(loop.instance.Status.Conditions, cloudformationv1alpha1.ConditionRecreating)
+	return loop.stack.StackStatus == cfTypes.StackStatusDeleteComplete && condition != nil &&
+		condition.Reason == cloudformationv1alpha1.ReasonDeletingFailedStack
+}
+
+// resetForRecreate forgets about the deleted failed stack, so that it is created from scratch.
+func (r *StackReconciler) resetForRecreate(loop *StackLoop) error {
+	loop.stack = nil
+
+	err := r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+		status.RecreateAttempts++
+		status.StackID = ""
+		status.StackStatus = ""
+		status.Outputs = nil
+		status.Resources = nil
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               cloudformationv1alpha1.ConditionRecreating,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: loop.instance.Generation,
+			Reason:             cloudformationv1alpha1.ReasonRecreating,
+			Message:            fmt.Sprintf("creating stack again, attempt %d", status.RecreateAttempts),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonRecreating,
+		"Creating stack again, attempt %d of %d", loop.instance.Status.RecreateAttempts, loop.instance.GetRecreateAttempts())
+	return nil
+}
+
+// finishRecreate marks a recreated stack as done once it was created successfully.
+func (r *StackReconciler) finishRecreate(loop *StackLoop) error {
+	condition := meta.FindStatusCondition(loop.instance.Status.Conditions, cloudformationv1alpha1.ConditionRecreating)
+	if condition == nil || condition.Status != metav1.ConditionTrue || stackCreateFailed(loop.stack.StackStatus) {
+		return nil
+	}
+
+	r.Log.WithValues("stack", loop.instance.Name).Info("stack recreated", "status", loop.stack.StackStatus)
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonRecreated,
+		"Stack created after %d attempts", loop.instance.Status.RecreateAttempts)
+	attempts := loop.instance.Status.RecreateAttempts
+	return r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+		status.RecreateAttempts = 0
+		status.FailureReason = ""
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               cloudformationv1alpha1.ConditionRecreating,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: loop.instance.Generation,
+			Reason:             cloudformationv1alpha1.ReasonRecreated,
+			Message:            fmt.Sprintf("stack is in %s after %d attempts", loop.stack.StackStatus, attempts),
+		})
+	})
+}
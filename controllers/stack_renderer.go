@@ -0,0 +1,113 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+// OperatorVariables are the operator-level values available when rendering a Stack.
+type OperatorVariables struct {
+	ClusterName string
+	AccountID   string
+	Region      string
+}
+
+// RenderStackVariables are the Stack's own values available when rendering a Stack.
+type RenderStackVariables struct {
+	Namespace   string
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// RenderContext is the data passed to the Go templates, e.g. `{{ .Stack.Namespace }}` or `{{ .Operator.ClusterName }}`.
+type RenderContext struct {
+	Stack    RenderStackVariables
+	Operator OperatorVariables
+}
+
+// newRenderContext builds the render context for a Stack.
+func newRenderContext(instance *cloudformationv1alpha1.Stack, operator OperatorVariables) RenderContext {
+	return RenderContext{
+		Stack: RenderStackVariables{
+			Namespace:   instance.Namespace,
+			Name:        instance.Name,
+			Labels:      instance.Labels,
+			Annotations: instance.Annotations,
+		},
+		Operator: operator,
+	}
+}
+
+// render executes a single Go template against the render context. The templates only get access
+// to plain data and the builtin functions, so they can't reach anything outside of the context.
+func render(name, text string, context RenderContext) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, context); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// renderError is returned when rendering a Stack failed. Rendering only depends on the Stack, so it
+// fails the same way until the Stack changes.
+type renderError struct {
+	message string
+}
+
+func (e *renderError) Error() string {
+	return e.message
+}
+
+// renderStack renders the template body and parameter values of a Stack.
+func renderStack(instance *cloudformationv1alpha1.Stack, templateBody string, operator OperatorVariables) (string, map[string]string, error) {
+	context := newRenderContext(instance, operator)
+
+	renderedBody, err := render("template", templateBody, context)
+	if err != nil {
+		return "", nil, &renderError{message: err.Error()}
+	}
+
+	renderedParameters := make(map[string]string, len(instance.Spec.Parameters))
+	for k, v := range instance.Spec.Parameters {
+		renderedParameters[k], err = render(k, v, context)
+		if err != nil {
+			return "", nil, &renderError{message: fmt.Sprintf("parameter %s: %v", k, err)}
+		}
+	}
+
+	return renderedBody, renderedParameters, nil
+}
@@ -0,0 +1,123 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+// refreshRequested returns whether the RefreshRequestAnnotation asks for a status refresh not handled yet.
+func refreshRequested(instance *cloudformationv1alpha1.Stack) bool {
+	request, ok := instance.Annotations[cloudformationv1alpha1.RefreshRequestAnnotation]
+	return ok && request != instance.Status.LastHandledRefreshRequest
+}
+
+// reconcileRequested returns whether the ReconcileRequestAnnotation asks for a reconcile not handled yet.
+func reconcileRequested(instance *cloudformationv1alpha1.Stack) bool {
+	request, ok := instance.Annotations[cloudformationv1alpha1.ReconcileRequestAnnotation]
+	return ok && request != instance.Status.LastHandledReconcileRequest
+}
+
+// refreshStack has the StackFollower poll the stack right away if a status refresh was requested. It works
+// while the Stack is paused, too.
+func (r *StackReconciler) refreshStack(loop *StackLoop) error {
+	if !refreshRequested(loop.instance) {
+		return nil
+	}
+	request := loop.instance.Annotations[cloudformationv1alpha1.RefreshRequestAnnotation]
+
+	r.Log.WithValues("stack", loop.instance.Name).Info("refreshing stack status", "request", request)
+	r.CloudFormationHelper.InvalidateStack(loop.instance)
+	r.StackFollower.Follow(loop.instance)
+
+	if err := r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+		status.LastHandledRefreshRequest = request
+	}); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonRefreshRequested,
+		"Refreshing stack status as requested by %s", request)
+	return nil
+}
+
+// pauseStack reports whether reconciling the Stack is paused and keeps the Paused condition up to date. A paused
+// stack is neither created nor updated, but an operation in progress is still followed.
+func (r *StackReconciler) pauseStack(loop *StackLoop) (bool, error) {
+	condition := meta.FindStatusCondition(loop.instance.Status.Conditions, cloudformationv1alpha1.ConditionPaused)
+	wasPaused := condition != nil && condition.Status == metav1.ConditionTrue
+
+	if !loop.instance.IsPaused() {
+		if !wasPaused {
+			return false, nil
+		}
+		r.Log.WithValues("stack", loop.instance.Name).Info("resuming stack")
+		r.Recorder.Event(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonResumed, "Resumed reconciling stack")
+		return false, r.setCondition(loop, cloudformationv1alpha1.ConditionPaused, metav1.ConditionFalse,
+			cloudformationv1alpha1.ReasonResumed, "")
+	}
+
+	r.Log.WithValues("stack", loop.instance.Name).Info("stack is paused")
+	if !wasPaused {
+		r.Recorder.Event(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonPaused, "Paused reconciling stack")
+	}
+	if err := r.setCondition(loop, cloudformationv1alpha1.ConditionPaused, metav1.ConditionTrue, cloudformationv1alpha1.ReasonPaused,
+		fmt.Sprintf("remove the %s annotation to resume reconciling the stack", cloudformationv1alpha1.PausedAnnotation)); err != nil {
+		return true, err
+	}
+
+	exists, err := r.stackExists(loop)
+	if err != nil {
+		return true, err
+	}
+	if exists && !r.CloudFormationHelper.StackInTerminalState(loop.stack.StackStatus) {
+		r.StackFollower.Follow(loop.instance)
+	}
+	return true, nil
+}
+
+// acknowledgeReconcileRequest records that a requested reconcile is carried out by this reconcile.
+func (r *StackReconciler) acknowledgeReconcileRequest(loop *StackLoop) error {
+	if !loop.forced {
+		return nil
+	}
+	request := loop.instance.Annotations[cloudformationv1alpha1.ReconcileRequestAnnotation]
+
+	r.Log.WithValues("stack", loop.instance.Name).Info("reconciling stack as requested", "request", request)
+	if err := r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+		status.LastHandledReconcileRequest = request
+	}); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeNormal, cloudformationv1alpha1.ReasonReconcileRequested,
+		"Reconciling stack as requested by %s", request)
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+const (
+	// Field managers of the writers of a Stack's status.
+	reconcilerFieldManager = "cloudformation-operator-reconciler"
+	followerFieldManager   = "cloudformation-operator-follower"
+)
+
+// patchStackStatus applies mutate to the status of the given Stack and patches the status subresource with the
+// changes only, using a dedicated field manager per writer. A patch based on an outdated Stack conflicts, in which
+// case the resource version and status of the latest Stack are read from the API server into instance and mutate is
+// applied again. The spec and metadata of instance are left alone, as the caller acts on them.
+func patchStackStatus(ctx context.Context, c client.Client, reader client.Reader, fieldManager string,
+	instance *cloudformationv1alpha1.Stack, mutate func(*cloudformationv1alpha1.StackStatus)) error {
+	if reader == nil {
+		reader = c
+	}
+
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if !first {
+			// Decoding into instance would reuse its maps and slices, which mutate may have set to values it
+			// still holds on to, so the latest Stack is read into a new object.
+			latest := &cloudformationv1alpha1.Stack{}
+			if err := reader.Get(ctx, client.ObjectKeyFromObject(instance), latest); err != nil {
+				return err
+			}
+			instance.ResourceVersion = latest.ResourceVersion
+			instance.Status = latest.Status
+		}
+		first = false
+
+		original := instance.DeepCopy()
+		mutate(&instance.Status)
+		if equality.Semantic.DeepEqual(original.Status, instance.Status) {
+			return nil
+		}
+
+		err := c.Status().Patch(ctx, instance, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}),
+			client.FieldOwner(fieldManager))
+		if errors.IsConflict(err) {
+			statusUpdateConflicts.WithLabelValues(fieldManager).Inc()
+		}
+		return err
+	})
+}
@@ -0,0 +1,82 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+var _ = Describe("Stack status", func() {
+	ctx := context.Background()
+
+	It("applies changes again to the latest status on conflicts", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "unfollowed",
+			Labels: map[string]string{ignoredNamespaceLabel: "true"},
+		}}
+		if err := k8sClient.Create(ctx, ns); !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		instance := &cloudformationv1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "unfollowed", Name: "conflict"},
+			Spec:       cloudformationv1alpha1.StackSpec{Template: bucketTemplate},
+		}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		defer func() {
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		}()
+
+		// Another writer changes the status after instance was read.
+		other := &cloudformationv1alpha1.Stack{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), other)).To(Succeed())
+		other.Status.StackStatus = "CREATE_IN_PROGRESS"
+		Expect(k8sClient.Status().Update(ctx, other)).To(Succeed())
+
+		// The spec of instance differs from the stored one, e.g. because it's being acted on.
+		instance.Spec.Parameters = map[string]string{"BucketName": "pending"}
+		Expect(patchStackStatus(ctx, k8sClient, nil, "test", instance, func(status *cloudformationv1alpha1.StackStatus) {
+			status.Outputs = map[string]string{"BucketName": "bucket"}
+		})).To(Succeed())
+		Expect(testutil.ToFloat64(statusUpdateConflicts.WithLabelValues("test"))).To(Equal(float64(1)))
+
+		latest := &cloudformationv1alpha1.Stack{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), latest)).To(Succeed())
+		Expect(latest.Status.StackStatus).To(Equal("CREATE_IN_PROGRESS"))
+		Expect(latest.Status.Outputs).To(Equal(map[string]string{"BucketName": "bucket"}))
+		Expect(instance.Status).To(Equal(latest.Status))
+		Expect(instance.ResourceVersion).To(Equal(latest.ResourceVersion))
+		Expect(instance.Spec.Parameters).To(Equal(map[string]string{"BucketName": "pending"}))
+	})
+})
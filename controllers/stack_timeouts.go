@@ -0,0 +1,225 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+// createTimeout returns the TimeoutInMinutes for creating the stack of a Stack, nil if it has none.
+func createTimeout(instance *cloudformationv1alpha1.Stack) *int32 {
+	timeouts := instance.Spec.Timeouts
+	if timeouts == nil || timeouts.Create == nil || timeouts.Create.Duration <= 0 {
+		return nil
+	}
+	return aws.Int32(int32((timeouts.Create.Duration + time.Minute - 1) / time.Minute))
+}
+
+// updateDeadline returns when the update of a stack in progress times out, false if it has no timeout.
+func updateDeadline(loop *StackLoop) (time.Time, bool) {
+	timeouts := loop.instance.Spec.Timeouts
+	if timeouts == nil || timeouts.Update == nil || timeouts.Update.Duration <= 0 {
+		return time.Time{}, false
+	}
+	started := loop.instance.Status.UpdatedTime.Time
+	if loop.stack.LastUpdatedTime != nil {
+		started = *loop.stack.LastUpdatedTime
+	}
+	return started.Add(timeouts.Update.Duration), true
+}
+
+// cancelRequested returns whether the CancelRequestAnnotation asks to cancel an update not handled yet.
+func cancelRequested(instance *cloudformationv1alpha1.Stack) bool {
+	request, ok := instance.Annotations[cloudformationv1alpha1.CancelRequestAnnotation]
+	return ok && request != instance.Status.LastHandledCancelRequest
+}
+
+// submittedGeneration returns the generation of the Stack whose update was submitted last, which is older than the
+// current one if the Stack changed while the update was in progress.
+func submittedGeneration(instance *cloudformationv1alpha1.Stack) int64 {
+	condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionSynced)
+	if condition == nil || condition.Reason != cloudformationv1alpha1.ReasonSubmitted {
+		return instance.Generation
+	}
+	return condition.ObservedGeneration
+}
+
+// cancelUpdate cancels the update of a stack in UPDATE_IN_PROGRESS if it was requested or the update took longer
+// than spec.timeouts.update. Otherwise the result requeues the Stack once the update times out.
+func (r *StackReconciler) cancelUpdate(loop *StackLoop) (ctrl.Result, error) {
+	if loop.stack.StackStatus != cfTypes.StackStatusUpdateInProgress {
+		return ctrl.Result{}, r.dismissCancelRequest(loop)
+	}
+
+	requested := cancelRequested(loop.instance)
+	request := loop.instance.Annotations[cloudformationv1alpha1.CancelRequestAnnotation]
+	reason := cloudformationv1alpha1.ReasonCancelRequested
+	message := fmt.Sprintf("cancelling update as requested by %s", request)
+	if !requested {
+		deadline, ok := updateDeadline(loop)
+		if !ok {
+			return ctrl.Result{}, nil
+		}
+		if wait := time.Until(deadline); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		reason = cloudformationv1alpha1.ReasonUpdateTimedOut
+		message = fmt.Sprintf("cancelling update which took longer than %s", loop.instance.Spec.Timeouts.Update.Duration)
+	}
+
+	r.Log.WithValues("stack", loop.instance.Name).Info("cancelling update", "reason", reason)
+	submitted := submittedGeneration(loop.instance)
+
+	if loop.settings.DryRun {
+		r.Log.WithValues("stack", loop.instance.Name).Info("skipping cancelling update")
+		return ctrl.Result{}, nil
+	}
+
+	hasOwnership, err := r.hasOwnership(loop)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !hasOwnership {
+		r.Log.WithValues("stack", loop.instance.Name).Info("no ownership")
+		r.recordNotOwned(loop)
+		return ctrl.Result{}, nil
+	}
+
+	input := &cloudformation.CancelUpdateStackInput{
+		StackName: loop.stack.StackId,
+	}
+
+	if _, err := r.CloudFormation.CancelUpdateStack(loop.ctx, input); err != nil {
+		r.Recorder.Eventf(loop.instance, corev1.EventTypeWarning, cloudformationv1alpha1.ReasonCancelUpdateFailed,
+			"Failed to cancel update: %v", err)
+		if statusErr := r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+			if requested {
+				status.LastHandledCancelRequest = request
+			}
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               cloudformationv1alpha1.ConditionCancelling,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: submitted,
+				Reason:             cloudformationv1alpha1.ReasonCancelUpdateFailed,
+				Message:            err.Error(),
+			})
+		}); statusErr != nil {
+			r.Log.WithValues("stack", loop.instance.Name).Error(statusErr, "error recording cancel failure")
+		}
+		// The update may have finished in the meantime, which is seen on the next poll.
+		if r.CloudFormationHelper.ClassifyError(err) == ErrorClassValidation {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	r.CloudFormationHelper.InvalidateStack(loop.instance)
+
+	eventType := corev1.EventTypeNormal
+	if reason == cloudformationv1alpha1.ReasonUpdateTimedOut {
+		eventType = corev1.EventTypeWarning
+	}
+	r.Recorder.Eventf(loop.instance, eventType, reason, "Cancelling update of stack %s, %s", loop.instance.GetStackName(), message)
+	return ctrl.Result{}, r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+		if requested {
+			status.LastHandledCancelRequest = request
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               cloudformationv1alpha1.ConditionCancelling,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: submitted,
+			Reason:             reason,
+			Message:            message,
+		})
+	})
+}
+
+// dismissCancelRequest records a request to cancel an update while none is in progress as handled, so that it
+// doesn't cancel a later update.
+func (r *StackReconciler) dismissCancelRequest(loop *StackLoop) error {
+	if !cancelRequested(loop.instance) {
+		return nil
+	}
+	request := loop.instance.Annotations[cloudformationv1alpha1.CancelRequestAnnotation]
+
+	status := "not created"
+	if loop.stack != nil {
+		status = string(loop.stack.StackStatus)
+	}
+	r.Log.WithValues("stack", loop.instance.Name).Info("no update to cancel", "request", request, "status", status)
+	r.Recorder.Eventf(loop.instance, corev1.EventTypeWarning, cloudformationv1alpha1.ReasonCancelUpdateFailed,
+		"No update to cancel as requested by %s, stack is %s", request, status)
+	return r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+		status.LastHandledCancelRequest = request
+	})
+}
+
+// finishCancel reports a cancelled update once it was rolled back. The update isn't submitted again until the
+// Stack changes or a reconcile is requested, so that a hanging update isn't retried over and over. It returns
+// whether the update is held back.
+func (r *StackReconciler) finishCancel(loop *StackLoop) (bool, error) {
+	condition := meta.FindStatusCondition(loop.instance.Status.Conditions, cloudformationv1alpha1.ConditionCancelling)
+	if condition == nil {
+		return false, nil
+	}
+	cancelledGeneration := condition.ObservedGeneration
+
+	if condition.Status == metav1.ConditionTrue {
+		r.Log.WithValues("stack", loop.instance.Name).Info("update cancelled", "status", loop.stack.StackStatus)
+		r.Recorder.Eventf(loop.instance, corev1.EventTypeWarning, cloudformationv1alpha1.ReasonCancelled,
+			"Update was cancelled and rolled back, stack is in %s", loop.stack.StackStatus)
+		if err := r.updateStatus(loop, func(status *cloudformationv1alpha1.StackStatus) {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               cloudformationv1alpha1.ConditionCancelling,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: cancelledGeneration,
+				Reason:             cloudformationv1alpha1.ReasonCancelled,
+				Message:            fmt.Sprintf("update was cancelled and rolled back to %s", loop.stack.StackStatus),
+			})
+		}); err != nil {
+			return true, err
+		}
+	} else if condition.Reason != cloudformationv1alpha1.ReasonCancelled {
+		return false, nil
+	}
+
+	if loop.forced || cancelledGeneration != loop.instance.Generation {
+		return false, nil
+	}
+	r.Log.WithValues("stack", loop.instance.Name).Info("not updating stack again until the Stack changes")
+	return true, nil
+}
@@ -25,20 +25,29 @@ SOFTWARE.
 package controllers
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	cloudformationlinkispacev1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+	"github.com/linki/cloudformation-operator/controllers/cloudformationfake"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -48,6 +57,17 @@ import (
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var fakeCloudFormation *cloudformationfake.CloudFormation
+var stopManager context.CancelFunc
+var operatorConfigFile string
+var operatorSettings *Settings
+
+const ignoredNamespaceLabel = "cloudformation.linki.space/ignored"
+
+const emptyOperatorConfig = `
+apiVersion: cloudformation.linki.space/v1alpha1
+kind: OperatorConfig
+`
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -78,9 +98,94 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	By("starting the operator against a fake CloudFormation")
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme.Scheme,
+		MetricsBindAddress: "0",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// Stacks in namespaces labelled ignoredNamespaceLabel=true aren't managed.
+	namespaceFilter := &NamespaceFilter{}
+	namespaceFilter.Selector, err = labels.Parse(ignoredNamespaceLabel + "!=true")
+	Expect(err).NotTo(HaveOccurred())
+	namespaceFilter.Cache, err = cache.New(cfg, cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(mgr.Add(namespaceFilter.Cache)).To(Succeed())
+
+	fakeCloudFormation = cloudformationfake.New()
+	// The stacks are looked up through a cache like with the operator's defaults, with a TTL which keeps
+	// the tests fast.
+	cfHelper := &CloudFormationHelper{
+		CloudFormation: fakeCloudFormation,
+		Cache:          NewStackCache(fakeCloudFormation, 100*time.Millisecond),
+	}
+
+	stackFollower := NewStackFollower(mgr.GetClient(), ctrl.Log.WithName("workers").WithName("Stack"), cfHelper)
+	stackFollower.APIReader = mgr.GetAPIReader()
+	stackFollower.Recorder = mgr.GetEventRecorderFor("cloudformation-operator")
+	stackFollower.PollInterval = 50 * time.Millisecond
+	stackFollower.MaxPollInterval = 200 * time.Millisecond
+	stackFollower.Namespaces = namespaceFilter
+	stackFollower.Stacks = &StackFilter{}
+	stackLocks := NewStackLocks()
+	stackFollower.StackLocks = stackLocks
+	Expect(mgr.Add(stackFollower)).To(Succeed())
+
+	configDir, err := ioutil.TempDir("", "operator-config")
+	Expect(err).NotTo(HaveOccurred())
+	operatorConfigFile = filepath.Join(configDir, "config.yaml")
+	Expect(ioutil.WriteFile(operatorConfigFile, []byte(emptyOperatorConfig), 0644)).To(Succeed())
+	operatorSettings = &Settings{}
+	settingsChanged := make(chan event.GenericEvent)
+	Expect(mgr.Add(&ConfigWatcher{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("config"),
+		Scheme:   mgr.GetScheme(),
+		Path:     operatorConfigFile,
+		Interval: 50 * time.Millisecond,
+		Settings: operatorSettings,
+		Changed:  settingsChanged,
+	})).To(Succeed())
+
+	err = (&StackReconciler{
+		Client:               mgr.GetClient(),
+		Log:                  ctrl.Log.WithName("controllers").WithName("Stack"),
+		Scheme:               mgr.GetScheme(),
+		APIReader:            mgr.GetAPIReader(),
+		Recorder:             mgr.GetEventRecorderFor("cloudformation-operator"),
+		CloudFormation:       fakeCloudFormation,
+		StackFollower:        stackFollower,
+		CloudFormationHelper: cfHelper,
+		Namespaces:           namespaceFilter,
+		Stacks:               &StackFilter{},
+		StackLocks:           stackLocks,
+		// Several workers make concurrent reconciles of different Stacks likely.
+		MaxConcurrentReconciles: 4,
+		RecreateDelay:           100 * time.Millisecond,
+		Settings:                operatorSettings,
+		SettingsChanged:         settingsChanged,
+	}).SetupWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+
+	var ctx context.Context
+	ctx, stopManager = context.WithCancel(context.Background())
+	go func() {
+		defer GinkgoRecover()
+		Expect(mgr.Start(ctx)).To(Succeed())
+	}()
 }, 60)
 
 var _ = AfterSuite(func() {
+	By("stopping the operator")
+	if stopManager != nil {
+		stopManager()
+	}
+
+	if operatorConfigFile != "" {
+		Expect(os.RemoveAll(filepath.Dir(operatorConfigFile))).To(Succeed())
+	}
+
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
@@ -0,0 +1,134 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+const tracerName = "github.com/linki/cloudformation-operator/controllers"
+
+// Attributes correlating spans with a Stack and its CloudFormation stack.
+var (
+	stackNameKey   = attribute.Key("cloudformation.linki.space/stack.name")
+	stackUIDKey    = attribute.Key("cloudformation.linki.space/stack.uid")
+	stackIDKey     = attribute.Key("aws.cloudformation.stack_id")
+	stackStatusKey = attribute.Key("aws.cloudformation.stack_status")
+)
+
+// TracingOptions configures the export of traces with OTLP.
+type TracingOptions struct {
+	// Endpoint of the OTLP gRPC receiver, OTEL_EXPORTER_OTLP_ENDPOINT is used if empty.
+	Endpoint string
+	// Insecure disables TLS when connecting to the receiver.
+	Insecure bool
+	// SampleRatio is the fraction of traces to sample.
+	SampleRatio float64
+}
+
+// NewTracerProvider creates a TracerProvider exporting spans with OTLP. Spans are only recorded once it's
+// registered with otel.SetTracerProvider.
+func NewTracerProvider(ctx context.Context, options TracingOptions) (*sdktrace.TracerProvider, error) {
+	var exporterOptions []otlptracegrpc.Option
+	if options.Endpoint != "" {
+		exporterOptions = append(exporterOptions, otlptracegrpc.WithEndpoint(options.Endpoint))
+	}
+	if options.Insecure {
+		exporterOptions = append(exporterOptions, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, exporterOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String("cloudformation-operator"))),
+	), nil
+}
+
+// tracer returns the tracer of the operator from the global TracerProvider, which doesn't record anything
+// unless tracing is enabled.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// stackAttributes returns the attributes identifying the given Stack and its CloudFormation stack.
+func stackAttributes(instance *cloudformationv1alpha1.Stack) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.K8SNamespaceNameKey.String(instance.Namespace),
+		stackNameKey.String(instance.Name),
+		stackUIDKey.String(string(instance.UID)),
+		stackIDKey.String(instance.Status.StackID),
+	}
+}
+
+// endSpan records the outcome of the operation traced by span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// AddTracing adds a middleware to the stack of an AWS client which traces every request, including its
+// retries, as a child of the span in the request's context. Add it to the APIOptions of the client.
+func AddTracing(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Tracing", func(
+		ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+	) (out middleware.InitializeOutput, metadata middleware.Metadata, err error) {
+		service := awsmiddleware.GetServiceID(ctx)
+		operation := awsmiddleware.GetOperationName(ctx)
+
+		ctx, span := tracer().Start(ctx, fmt.Sprintf("%s.%s", service, operation),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.RPCSystemKey.String("aws-api"),
+				semconv.RPCServiceKey.String(service),
+				semconv.RPCMethodKey.String(operation),
+			))
+		defer func() { endSpan(span, err) }()
+
+		return next.HandleInitialize(ctx, in)
+	}), middleware.After)
+}
@@ -0,0 +1,135 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/smithy-go/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const describeStacksResponse = `<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+  <DescribeStacksResult>
+    <Stacks>
+      <member>
+        <StackName>my-stack</StackName>
+        <StackId>arn:aws:cloudformation:us-east-1:123456789012:stack/my-stack/1</StackId>
+        <StackStatus>CREATE_COMPLETE</StackStatus>
+        <CreationTime>2021-01-01T00:00:00Z</CreationTime>
+      </member>
+    </Stacks>
+  </DescribeStacksResult>
+  <ResponseMetadata>
+    <RequestId>1</RequestId>
+  </ResponseMetadata>
+</DescribeStacksResponse>`
+
+var _ = Describe("Tracing", func() {
+	var exporter *tracetest.InMemoryExporter
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+
+	It("traces AWS requests as children of the current span", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/xml")
+			_, _ = w.Write([]byte(describeStacksResponse))
+		}))
+		defer server.Close()
+
+		client := cloudformation.New(cloudformation.Options{
+			Region:           "us-east-1",
+			Credentials:      credentials.NewStaticCredentialsProvider("key", "secret", ""),
+			EndpointResolver: cloudformation.EndpointResolverFromURL(server.URL),
+			APIOptions:       []func(*middleware.Stack) error{AddTracing},
+		})
+
+		ctx, parent := tracer().Start(context.Background(), "parent")
+		_, err := client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String("my-stack")})
+		Expect(err).NotTo(HaveOccurred())
+		parent.End()
+
+		spans := spansOfTrace(exporter, parent.SpanContext().TraceID())
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name).To(Equal("CloudFormation.DescribeStacks"))
+		Expect(spans[0].SpanKind).To(Equal(trace.SpanKindClient))
+		Expect(spans[0].Parent.SpanID()).To(Equal(parent.SpanContext().SpanID()))
+	})
+
+	It("traces reconciles with the Stack they belong to", func() {
+		reconciler := &StackReconciler{
+			Client: k8sClient,
+			Log:    logf.Log.WithName("test"),
+		}
+
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Namespace: "default", Name: "missing"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		var reconciles []tracetest.SpanStub
+		for _, span := range exporter.GetSpans() {
+			for _, attribute := range span.Attributes {
+				if attribute == stackNameKey.String("missing") {
+					reconciles = append(reconciles, span)
+				}
+			}
+		}
+		Expect(reconciles).To(HaveLen(1))
+		Expect(reconciles[0].Name).To(Equal("Reconcile"))
+	})
+})
+
+// spansOfTrace returns the exported spans of the given trace, leaving out those of the operator
+// running in the background.
+func spansOfTrace(exporter *tracetest.InMemoryExporter, traceID trace.TraceID) []tracetest.SpanStub {
+	var spans []tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		if span.SpanContext.TraceID() == traceID {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
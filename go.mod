@@ -3,15 +3,26 @@ module github.com/linki/cloudformation-operator
 go 1.15
 
 require (
-	github.com/aws/aws-sdk-go-v2 v1.3.0
-	github.com/aws/aws-sdk-go-v2/config v1.1.3
-	github.com/aws/aws-sdk-go-v2/credentials v1.1.3
-	github.com/aws/aws-sdk-go-v2/service/cloudformation v1.2.0
-	github.com/aws/aws-sdk-go-v2/service/sts v1.2.0
+	github.com/aws/aws-sdk-go-v2 v1.17.1
+	github.com/aws/aws-sdk-go-v2/config v1.17.7
+	github.com/aws/aws-sdk-go-v2/credentials v1.12.20
+	github.com/aws/aws-sdk-go-v2/service/cloudformation v1.23.0
+	github.com/aws/aws-sdk-go-v2/service/sts v1.16.19
+	github.com/aws/smithy-go v1.13.4
 	github.com/go-logr/logr v0.4.0
 	github.com/onsi/ginkgo v1.15.2
 	github.com/onsi/gomega v1.11.0
+	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/spf13/pflag v1.0.5
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+	golang.org/x/sync v0.2.0
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776
+	k8s.io/api v0.20.5
 	k8s.io/apimachinery v0.20.5
 	k8s.io/client-go v0.20.5
 	sigs.k8s.io/controller-runtime v0.8.3
@@ -27,6 +27,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
@@ -34,18 +35,24 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 
 	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
 	"github.com/linki/cloudformation-operator/controllers"
@@ -70,20 +77,74 @@ func init() {
 	StackFlagSet.StringToString("tag", map[string]string{}, "Tags to apply to all Stacks by default. Specify multiple times for multiple tags.")
 	StackFlagSet.StringSlice("capability", []string{}, "The AWS CloudFormation capability to enable")
 	StackFlagSet.Bool("dry-run", false, "If true, don't actually do anything.")
+	StackFlagSet.Float64("aws-rate-limit", 5, "The number of AWS API requests per second allowed per account and region")
+	StackFlagSet.Int("aws-rate-burst", 10, "The number of AWS API requests allowed in a burst per account and region")
+	StackFlagSet.Int("aws-max-attempts", 5, "The maximum number of attempts of an AWS API request, including retries")
+	StackFlagSet.Duration("aws-max-backoff", 20*time.Second, "The maximum time to wait before retrying an AWS API request")
+	StackFlagSet.String("aws-retry-mode", controllers.RetryModeAdaptiveRateLimit, "How to retry AWS API requests, `standard` keeps the rate limit fixed while `adaptive-rate-limit` lowers it when requests are throttled")
+	StackFlagSet.String("aws-endpoint-url", "", "Send AWS API requests to this URL instead of the AWS endpoints, e.g. for LocalStack")
+	StackFlagSet.String("aws-cloudformation-endpoint-url", "", "Send CloudFormation API requests to this URL, e.g. a VPC endpoint. Takes precedence over aws-endpoint-url")
+	StackFlagSet.String("aws-sts-endpoint-url", "", "Send STS API requests to this URL, e.g. a VPC endpoint. Takes precedence over aws-endpoint-url")
+	StackFlagSet.Bool("aws-use-fips-endpoint", false, "Use the FIPS endpoints of CloudFormation and STS")
+	StackFlagSet.Bool("aws-use-dualstack-endpoint", false, "Use the dual-stack (IPv4 and IPv6) endpoints of CloudFormation and STS")
+	StackFlagSet.String("aws-http-proxy", "", "The URL of the proxy for AWS API requests, HTTPS_PROXY and NO_PROXY are used if empty")
+	StackFlagSet.String("aws-ca-bundle", "", "A file of PEM encoded certificates to trust instead of the system's when connecting to AWS")
+	StackFlagSet.String("cluster-name", "", "The name of the Kubernetes cluster, available as .Operator.ClusterName when rendering Stacks")
 }
 
 func main() {
+	var configFile string
+	var configReloadInterval time.Duration
 	var namespace string
+	var namespaceSelector string
+	var operatorClass string
+	var leaderElectionID string
+	var stackSelector string
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var resyncInterval time.Duration
+	var pollInterval time.Duration
+	var maxPollInterval time.Duration
+	var pollJitter float64
+	var stackCacheTTL time.Duration
+	var maxConcurrentReconciles int
+	var tracingOptions controllers.TracingOptions
 
-	flag.StringVar(&namespace, "namespace", "", "The Kubernetes namespace to watch")
+	flag.StringVar(&configFile, "config", "",
+		"The operator's config file. Command-line flags given explicitly take precedence over its manager and AWS settings.")
+	flag.DurationVar(&configReloadInterval, "config-reload-interval", 10*time.Second,
+		"How often to check the config file for changes to the defaults, policy and namespaces of Stacks.")
+	flag.StringVar(&namespace, "namespace", "", "The Kubernetes namespace to watch, or a comma-separated list of namespaces")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "",
+		"Only manage Stacks in namespaces with labels matching this selector, e.g. `team=platform`")
+	flag.StringVar(&operatorClass, "operator-class", "",
+		"Only manage Stacks of this class, given by spec.operatorClass or the cloudformation.linki.space/operator-class annotation. Stacks without a class are managed if empty.")
+	flag.StringVar(&stackSelector, "stack-selector", "",
+		"Only manage Stacks with labels matching this selector, e.g. `shard=a`")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.DurationVar(&resyncInterval, "resync-interval", 10*time.Minute,
+		"How often to refresh the status of all Stacks from CloudFormation. Set to 0 to disable.")
+	flag.DurationVar(&pollInterval, "poll-interval", 5*time.Second,
+		"How long to wait before polling a stack after an operation started or its status changed.")
+	flag.DurationVar(&maxPollInterval, "max-poll-interval", time.Minute,
+		"The longest interval between polls of a stack whose status doesn't change.")
+	flag.Float64Var(&pollJitter, "poll-jitter", 0.1,
+		"Fraction of the poll interval added at random to spread out polling stacks.")
+	flag.DurationVar(&stackCacheTTL, "stack-cache-ttl", 5*time.Second,
+		"How long stacks described by account-wide DescribeStacks calls are reused. Set to 0 to describe each stack on its own.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of Stacks reconciled at once. Operations on the same stack never overlap.")
+	flag.StringVar(&tracingOptions.Endpoint, "otlp-endpoint", "",
+		"The OTLP gRPC endpoint to export traces to. Tracing is disabled unless this or OTEL_EXPORTER_OTLP_ENDPOINT is set.")
+	flag.BoolVar(&tracingOptions.Insecure, "otlp-insecure", false, "Connect to the OTLP endpoint without TLS.")
+	flag.Float64Var(&tracingOptions.SampleRatio, "trace-sample-ratio", 1, "The fraction of traces to sample.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "",
+		"The name of the lock used for leader election, operator instances managing different Stacks need different ones. Prefixed with operator-class if set.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -96,23 +157,122 @@ func main() {
 	if namespace == "" {
 		namespace = os.Getenv("WATCH_NAMESPACE")
 	}
+	if namespaceSelector == "" {
+		namespaceSelector = os.Getenv("WATCH_NAMESPACE_SELECTOR")
+	}
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if pollInterval <= 0 || maxPollInterval < pollInterval {
+		setupLog.Error(fmt.Errorf("poll-interval must be positive and max-poll-interval at least poll-interval, got %s and %s",
+			pollInterval, maxPollInterval), "invalid poll intervals")
+		os.Exit(1)
+	}
+
+	if tracingOptions.Endpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		tracerProvider, err := controllers.NewTracerProvider(context.TODO(), tracingOptions)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		defer func() {
+			if err := tracerProvider.Shutdown(context.TODO()); err != nil {
+				setupLog.Error(err, "problem flushing traces")
+			}
+		}()
+		otel.SetTracerProvider(tracerProvider)
+	}
+
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "64032969.cloudformation.linki.space",
+		LeaderElectionID:       leaderElectionID,
 		Namespace:              namespace,
-	})
+	}
+	operatorConfig := &cloudformationv1alpha1.OperatorConfig{}
+	if configFile != "" {
+		// Flags which weren't given are left empty, so that the config file can set them.
+		if !pflag.CommandLine.Changed("metrics-bind-address") {
+			options.MetricsBindAddress = ""
+		}
+		if !pflag.CommandLine.Changed("health-probe-bind-address") {
+			options.HealthProbeBindAddress = ""
+		}
+		var err error
+		options, err = options.AndFrom(ctrl.ConfigFile().AtPath(configFile).OfKind(operatorConfig))
+		if err != nil {
+			setupLog.Error(err, "unable to load the config file", "path", configFile)
+			os.Exit(1)
+		}
+		if options.MetricsBindAddress == "" {
+			options.MetricsBindAddress = metricsAddr
+		}
+		if options.HealthProbeBindAddress == "" {
+			options.HealthProbeBindAddress = probeAddr
+		}
+	}
+	if options.Port == 0 {
+		options.Port = 9443
+	}
+	if options.LeaderElectionID == "" {
+		options.LeaderElectionID = "64032969.cloudformation.linki.space"
+	}
+	// Operators of different classes never share a lock, wherever it's configured.
+	if operatorClass != "" {
+		options.LeaderElectionID = operatorClass + "." + options.LeaderElectionID
+	}
+
+	// Several namespaces get a cache each, so that the operator doesn't need access to all namespaces.
+	namespaces := controllers.SplitNamespaces(options.Namespace)
+	if len(namespaces) > 1 {
+		options.Namespace = ""
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	var namespaceFilter *controllers.NamespaceFilter
+	if len(namespaces) > 0 || namespaceSelector != "" {
+		namespaceFilter = &controllers.NamespaceFilter{Namespaces: namespaces}
+		setupLog.Info("managing Stacks in selected namespaces", "namespaces", namespaces, "selector", namespaceSelector)
+	}
+	if namespaceSelector != "" {
+		if namespaceFilter.Selector, err = labels.Parse(namespaceSelector); err != nil {
+			setupLog.Error(err, "invalid namespace selector")
+			os.Exit(1)
+		}
+		// Namespaces are cluster-scoped, so they get a cache of their own whichever namespaces are watched.
+		if namespaceFilter.Cache, err = cache.New(mgr.GetConfig(), cache.Options{
+			Scheme: mgr.GetScheme(),
+			Mapper: mgr.GetRESTMapper(),
+		}); err != nil {
+			setupLog.Error(err, "unable to create namespace cache")
+			os.Exit(1)
+		}
+		if err := mgr.Add(namespaceFilter.Cache); err != nil {
+			setupLog.Error(err, "unable to add namespace cache")
+			os.Exit(1)
+		}
+	}
+
+	stackFilter := &controllers.StackFilter{OperatorClass: operatorClass}
+	if stackSelector != "" {
+		if stackFilter.Selector, err = labels.Parse(stackSelector); err != nil {
+			setupLog.Error(err, "invalid stack selector")
+			os.Exit(1)
+		}
+	}
+	if operatorClass != "" || stackSelector != "" {
+		setupLog.Info("managing selected Stacks", "operatorClass", operatorClass, "selector", stackSelector)
+	}
+
 	assumeRole, err := StackFlagSet.GetString("assume-role")
 	if err != nil {
 		setupLog.Error(err, "error parsing flag")
@@ -123,6 +283,12 @@ func main() {
 		setupLog.Error(err, "error parsing flag")
 		os.Exit(1)
 	}
+	if assumeRole == "" {
+		assumeRole = operatorConfig.AssumeRole
+	}
+	if region == "" {
+		region = operatorConfig.Region
+	}
 	defaultTags, err := StackFlagSet.GetStringToString("tag")
 	if err != nil {
 		setupLog.Error(err, "error parsing flag")
@@ -145,7 +311,89 @@ func main() {
 		os.Exit(1)
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+	clusterName, err := StackFlagSet.GetString("cluster-name")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if clusterName == "" {
+		clusterName = operatorConfig.ClusterName
+	}
+
+	settings := &controllers.Settings{
+		Defaults: controllers.StackSettings{
+			Tags:         defaultTags,
+			Capabilities: defaultCapabilities,
+			DryRun:       dryRun,
+		},
+	}
+	if configFile != "" {
+		settings.SetConfig(operatorConfig)
+	}
+
+	rateLimit, err := StackFlagSet.GetFloat64("aws-rate-limit")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	rateBurst, err := StackFlagSet.GetInt("aws-rate-burst")
+	if err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
+	awsOptions := controllers.AWSOptions{Region: region}
+	if awsOptions.MaxAttempts, err = StackFlagSet.GetInt("aws-max-attempts"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.MaxBackoff, err = StackFlagSet.GetDuration("aws-max-backoff"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.RetryMode, err = StackFlagSet.GetString("aws-retry-mode"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.EndpointURL, err = StackFlagSet.GetString("aws-endpoint-url"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.CloudFormationEndpointURL, err = StackFlagSet.GetString("aws-cloudformation-endpoint-url"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.STSEndpointURL, err = StackFlagSet.GetString("aws-sts-endpoint-url"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.UseFIPSEndpoint, err = StackFlagSet.GetBool("aws-use-fips-endpoint"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.UseDualStackEndpoint, err = StackFlagSet.GetBool("aws-use-dualstack-endpoint"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.HTTPProxy, err = StackFlagSet.GetString("aws-http-proxy"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+	if awsOptions.CABundle, err = StackFlagSet.GetString("aws-ca-bundle"); err != nil {
+		setupLog.Error(err, "error parsing flag")
+		os.Exit(1)
+	}
+
+	configOptions, err := awsOptions.LoadOptions()
+	if err != nil {
+		setupLog.Error(err, "invalid AWS options")
+		os.Exit(1)
+	}
+	if awsOptions.EndpointURL != "" || awsOptions.CloudFormationEndpointURL != "" || awsOptions.STSEndpointURL != "" {
+		setupLog.Info("sending AWS API requests to custom endpoints", "url", awsOptions.EndpointURL,
+			"cloudformation", awsOptions.CloudFormationEndpointURL, "sts", awsOptions.STSEndpointURL)
+	}
+	cfg, err := config.LoadDefaultConfig(context.TODO(), configOptions...)
 	if err != nil {
 		setupLog.Error(err, "error getting AWS config")
 		os.Exit(1)
@@ -159,37 +407,95 @@ func main() {
 		creds = stscreds.NewAssumeRoleProvider(stsClient, assumeRole)
 	}
 
+	operatorVariables := controllers.OperatorVariables{
+		ClusterName: clusterName,
+		Region:      cfg.Region,
+	}
+	identity, err := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		o.Credentials = creds
+	}).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		setupLog.Error(err, "unable to determine AWS account ID")
+	} else {
+		operatorVariables.AccountID = *identity.Account
+	}
+
+	rateLimiter := controllers.APIRateLimiterFor(operatorVariables.AccountID, cfg.Region, rateLimit, rateBurst)
+	rateLimiter.SetAdaptive(awsOptions.RetryMode == controllers.RetryModeAdaptiveRateLimit)
 	client := cloudformation.NewFromConfig(cfg, func(o *cloudformation.Options) {
 		o.Credentials = creds
+		o.APIOptions = append(o.APIOptions, rateLimiter.AddToStack, controllers.AddAPIMetrics, controllers.AddTracing)
 	})
 
 	cfHelper := &controllers.CloudFormationHelper{
 		CloudFormation: client,
 	}
+	if stackCacheTTL > 0 {
+		cfHelper.Cache = controllers.NewStackCache(client, stackCacheTTL)
+	}
 
-	stackFollower := &controllers.StackFollower{
-		Client:               mgr.GetClient(),
-		Log:                  ctrl.Log.WithName("workers").WithName("Stack"),
-		SubmissionChannel:    make(chan *cloudformationv1alpha1.Stack),
-		CloudFormationHelper: cfHelper,
+	stackLocks := controllers.NewStackLocks()
+	stackFollower := controllers.NewStackFollower(mgr.GetClient(), ctrl.Log.WithName("workers").WithName("Stack"), cfHelper)
+	stackFollower.APIReader = mgr.GetAPIReader()
+	stackFollower.Recorder = mgr.GetEventRecorderFor("cloudformation-operator")
+	stackFollower.PollInterval = pollInterval
+	stackFollower.MaxPollInterval = maxPollInterval
+	stackFollower.PollJitter = pollJitter
+	stackFollower.ResyncInterval = resyncInterval
+	stackFollower.Namespaces = namespaceFilter
+	stackFollower.Stacks = stackFilter
+	stackFollower.StackLocks = stackLocks
+	if err := mgr.Add(stackFollower); err != nil {
+		setupLog.Error(err, "unable to add stack follower")
+		os.Exit(1)
 	}
-	go stackFollower.Receiver()
-	go stackFollower.Worker()
+
+	var settingsChanged chan event.GenericEvent
+	if configFile != "" {
+		settingsChanged = make(chan event.GenericEvent)
+		if err := mgr.Add(&controllers.ConfigWatcher{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("config"),
+			Scheme:   mgr.GetScheme(),
+			Path:     configFile,
+			Interval: configReloadInterval,
+			Settings: settings,
+			Changed:  settingsChanged,
+		}); err != nil {
+			setupLog.Error(err, "unable to add config watcher")
+			os.Exit(1)
+		}
+	}
+
+	metrics.Registry.MustRegister(controllers.NewStackCollector(mgr.GetClient()))
 
 	if err = (&controllers.StackReconciler{
 		Client:               mgr.GetClient(),
 		Log:                  ctrl.Log.WithName("controllers").WithName("Stack"),
 		Scheme:               mgr.GetScheme(),
+		APIReader:            mgr.GetAPIReader(),
+		Recorder:             mgr.GetEventRecorderFor("cloudformation-operator"),
 		CloudFormation:       client,
 		StackFollower:        stackFollower,
 		CloudFormationHelper: cfHelper,
-		DefaultTags:          defaultTags,
-		DefaultCapabilities:  defaultCapabilities,
-		DryRun:               dryRun,
+		Namespaces:           namespaceFilter,
+		Stacks:               stackFilter,
+		StackLocks:           stackLocks,
+		Settings:             settings,
+		SettingsChanged:      settingsChanged,
+		OperatorVariables:    operatorVariables,
+
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Stack")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&cloudformationv1alpha1.Stack{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Stack")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("health", healthz.Ping); err != nil {
@@ -0,0 +1,182 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package e2e
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+)
+
+const bucketTemplate = `
+Parameters:
+  BucketName:
+    Type: String
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Ref BucketName
+Outputs:
+  BucketName:
+    Value: !Ref BucketName
+`
+
+const (
+	timeout  = 20 * time.Second
+	interval = 100 * time.Millisecond
+)
+
+var _ = Describe("Operator", func() {
+	ctx := context.Background()
+
+	getStack := func(name string) (*cloudformationv1alpha1.Stack, error) {
+		instance := &cloudformationv1alpha1.Stack{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, instance)
+		return instance, err
+	}
+
+	stackStatus := func(name string) func() string {
+		return func() string {
+			instance, err := getStack(name)
+			if err != nil {
+				return ""
+			}
+			return instance.Status.StackStatus
+		}
+	}
+
+	It("creates, updates and deletes stacks", func() {
+		Expect(k8sClient.Create(ctx, &cloudformationv1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "lifecycle"},
+			Spec: cloudformationv1alpha1.StackSpec{
+				Template:       bucketTemplate,
+				RenderTemplate: true,
+				Parameters:     map[string]string{"BucketName": "{{ .Operator.AccountID }}-{{ .Operator.Region }}-bucket"},
+			},
+		})).To(Succeed())
+
+		By("creating the stack")
+		Eventually(stackStatus("lifecycle"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("lifecycle"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+
+		instance, err := getStack("lifecycle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.Outputs).To(HaveKeyWithValue("BucketName", "123456789012-us-east-1-bucket"))
+		Expect(instance.Status.Resources).To(HaveLen(1))
+
+		stack, ok := fakeCloudFormation.Stack("lifecycle")
+		Expect(ok).To(BeTrue())
+		Expect(instance.Status.StackID).To(Equal(aws.ToString(stack.StackId)))
+		Expect(stack.Tags).To(ContainElement(cfTypes.Tag{Key: aws.String("environment"), Value: aws.String("e2e")}))
+
+		By("updating the stack")
+		Eventually(func() error {
+			instance, err := getStack("lifecycle")
+			if err != nil {
+				return err
+			}
+			instance.Spec.Parameters["BucketName"] = "renamed-bucket"
+			return k8sClient.Update(ctx, instance)
+		}, timeout, interval).Should(Succeed())
+		Eventually(stackStatus("lifecycle"), timeout, interval).Should(Equal("UPDATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("lifecycle"), timeout, interval).Should(Equal("UPDATE_COMPLETE"))
+
+		instance, err = getStack("lifecycle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.Status.Outputs).To(HaveKeyWithValue("BucketName", "renamed-bucket"))
+
+		By("deleting the stack")
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		Eventually(stackStatus("lifecycle"), timeout, interval).Should(Equal("DELETE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(func() bool {
+			_, err := getStack("lifecycle")
+			return errors.IsNotFound(err)
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("reports errors returned by CloudFormation", func() {
+		Expect(k8sClient.Create(ctx, &cloudformationv1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rejected"},
+			Spec: cloudformationv1alpha1.StackSpec{
+				Template: `
+Resources:
+  Role:
+    Type: AWS::IAM::Role
+`,
+			},
+		})).To(Succeed())
+
+		Eventually(func() string {
+			instance, err := getStack("rejected")
+			if err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(instance.Status.Conditions, cloudformationv1alpha1.ConditionSynced)
+			if condition == nil {
+				return ""
+			}
+			return condition.Reason
+		}, timeout, interval).Should(Equal(cloudformationv1alpha1.ReasonInsufficientCapabilities))
+
+		instance, err := getStack("rejected")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		Eventually(func() bool {
+			_, err := getStack("rejected")
+			return errors.IsNotFound(err)
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("retries throttled requests", func() {
+		fakeCloudFormation.Throttle(2)
+
+		Expect(k8sClient.Create(ctx, &cloudformationv1alpha1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "throttled"},
+			Spec: cloudformationv1alpha1.StackSpec{
+				Template:   bucketTemplate,
+				Parameters: map[string]string{"BucketName": "throttled-bucket"},
+			},
+		})).To(Succeed())
+
+		Eventually(stackStatus("throttled"), timeout, interval).Should(Equal("CREATE_IN_PROGRESS"))
+		fakeCloudFormation.Tick()
+		Eventually(stackStatus("throttled"), timeout, interval).Should(Equal("CREATE_COMPLETE"))
+	})
+})
@@ -0,0 +1,151 @@
+/*
+MIT License
+
+Copyright (c) 2018 Martin Linkhorst
+Copyright (c) 2021 Stephen Cuppett
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	cloudformationv1alpha1 "github.com/linki/cloudformation-operator/api/v1alpha1"
+	"github.com/linki/cloudformation-operator/controllers/cloudformationfake"
+)
+
+// These tests build the operator and run it against a Kubernetes API server started by envtest and a
+// fake CloudFormation served over HTTP, so that everything main.go wires up takes part.
+
+// kubeconfigTemplate points the operator at the insecure port of envtest's API server.
+const kubeconfigTemplate = `apiVersion: v1
+kind: Config
+clusters:
+- name: envtest
+  cluster:
+    server: http://%s
+contexts:
+- name: envtest
+  context:
+    cluster: envtest
+current-context: envtest
+`
+
+var k8sClient client.Client
+var testEnv *envtest.Environment
+var fakeCloudFormation *cloudformationfake.CloudFormation
+var cloudFormationServer *httptest.Server
+var operator *gexec.Session
+var tempDir string
+
+func TestE2E(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecsWithDefaultAndCustomReporters(t,
+		"E2E Suite",
+		[]Reporter{printer.NewlineReporter{}})
+}
+
+var _ = BeforeSuite(func() {
+	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+
+	By("bootstrapping test environment")
+	testEnv = &envtest.Environment{
+		CRDDirectoryPaths: []string{filepath.Join("..", "..", "config", "crd", "bases")},
+	}
+
+	cfg, err := testEnv.Start()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(cfg).NotTo(BeNil())
+
+	err = cloudformationv1alpha1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	Expect(err).NotTo(HaveOccurred())
+
+	By("serving a fake CloudFormation")
+	fakeCloudFormation = cloudformationfake.New()
+	cloudFormationServer = httptest.NewServer(cloudformationfake.NewServer(fakeCloudFormation))
+
+	By("building the operator")
+	binary, err := gexec.Build("github.com/linki/cloudformation-operator")
+	Expect(err).NotTo(HaveOccurred())
+
+	tempDir, err = ioutil.TempDir("", "cloudformation-operator-e2e")
+	Expect(err).NotTo(HaveOccurred())
+	kubeconfig := filepath.Join(tempDir, "kubeconfig")
+	Expect(ioutil.WriteFile(kubeconfig, []byte(fmt.Sprintf(kubeconfigTemplate, cfg.Host)), 0600)).To(Succeed())
+
+	By("starting the operator")
+	command := exec.Command(binary,
+		"--region=us-east-1",
+		"--aws-endpoint-url="+cloudFormationServer.URL,
+		"--metrics-bind-address=0",
+		"--health-probe-bind-address=0",
+		"--poll-interval=100ms",
+		"--max-poll-interval=500ms",
+		"--stack-cache-ttl=100ms",
+		"--tag=environment=e2e",
+	)
+	command.Env = append(os.Environ(),
+		"KUBECONFIG="+kubeconfig,
+		"ENABLE_WEBHOOKS=false",
+		"AWS_ACCESS_KEY_ID=fake",
+		"AWS_SECRET_ACCESS_KEY=fake",
+	)
+	operator, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+	Expect(err).NotTo(HaveOccurred())
+}, 300)
+
+var _ = AfterSuite(func() {
+	By("stopping the operator")
+	if operator != nil {
+		operator.Terminate().Wait(10)
+	}
+	if cloudFormationServer != nil {
+		cloudFormationServer.Close()
+	}
+	gexec.CleanupBuildArtifacts()
+	if tempDir != "" {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	}
+
+	By("tearing down the test environment")
+	err := testEnv.Stop()
+	Expect(err).NotTo(HaveOccurred())
+})